@@ -3,13 +3,18 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"golang-orders-app/config"
 	"golang-orders-app/handler"
-
+	"golang-orders-app/middleware"
+	"golang-orders-app/notifier"
 	"golang-orders-app/repository"
+	"golang-orders-app/throttle"
+	"golang-orders-app/utils"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 func main() {
@@ -23,22 +28,120 @@ func main() {
 	}
 	defer db.Close()
 
+	// Load the keys access tokens are signed and verified with
+	keySet, err := utils.LoadKeySet(cfg.JWTKeysDir, cfg.JWTActiveKID, cfg.JWTSecretKey, cfg.JWTAcceptLegacyHS256)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	utils.SetKeySet(keySet)
+
+	// Deliver verification and other user notifications
+	userNotifier, err := notifier.New(cfg.Notifier, cfg.NotifierFile)
+	if err != nil {
+		log.Fatalf("Failed to configure notifier: %v", err)
+	}
+
 	// Initialize repositories and handlers
 	userRepo := repository.NewUserRepository(db)
-	orderRepo := repository.NewOrderRepository(db) 
-	userHandler := handler.NewUserHandler(userRepo)
+	orderRepo := repository.NewOrderRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revokedTokenRepo := repository.NewRevokedTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	bulkOrderJobRepo := repository.NewBulkOrderJobRepository(db)
+	idempotencyKeyRepo := repository.NewIdempotencyKeyRepository(db)
+
+	// Failed login counters and rate limits live in memory unless instances must share them
+	var throttleStore throttle.Store = throttle.NewMemoryStore()
+	var windowStore throttle.WindowStore = throttle.NewMemoryWindowStore()
+	if cfg.LoginThrottleStore == "postgres" {
+		throttleStore = repository.NewLoginThrottleRepository(db)
+		rateLimitRepo := repository.NewRateLimitRepository(db)
+		rateLimitRepo.StartPruning(time.Hour, time.Hour)
+		windowStore = rateLimitRepo
+	}
+	loginThrottle := throttle.New(throttleStore)
+	trackingLimiter := throttle.NewRateLimiter(30, time.Minute, windowStore)
+
+	userHandler := handler.NewUserHandler(userRepo, refreshTokenRepo, revokedTokenRepo, sessionRepo, loginAttemptRepo, loginThrottle, userNotifier)
 	orderHandler := handler.NewOrderHandler(orderRepo)
+	bulkOrderHandler := handler.NewBulkOrderHandler(orderRepo, bulkOrderJobRepo)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyRepo)
+	jwksHandler := handler.NewJWKSHandler(keySet)
+
+	// Drop revocations of tokens that have expired anyway
+	revokedTokenRepo.StartPruning(time.Hour)
+	// Drop idempotency keys older than their replay window
+	idempotencyKeyRepo.StartPruning(time.Hour)
+	// Fail bulk uploads left unfinished by a restart; running jobs report progress every batch
+	bulkOrderJobRepo.StartFailingStale(time.Minute, 10*time.Minute)
 
 	// Initialize Chi router
 	r := chi.NewRouter()
+	if cfg.TrustProxyHeaders {
+		r.Use(chimiddleware.RealIP)
+	}
 	// Register routes
+	r.Get("/.well-known/jwks.json", jwksHandler.JWKS)
 	r.Route("/api/v1", func(r chi.Router) {
+		r.Post("/register", userHandler.RegisterHandler)
+		r.Post("/register/verify", userHandler.VerifyHandler)
+		r.Post("/register/resend", userHandler.ResendVerificationHandler)
 		r.Post("/login", userHandler.LoginHandler)
-		r.Post("/logout", userHandler.LogoutHandler)
-		r.Post("/orders", orderHandler.CreateOrder)
-		r.Get("/orders/all", orderHandler.ListOrders)
-		r.Put("/orders/{consignmentID}/cancel", orderHandler.CancelOrderHandler)
+		r.Post("/login/mfa", userHandler.MFALoginHandler)
+		r.Post("/token/refresh", userHandler.RefreshTokenHandler)
+		r.Post("/password/forgot", userHandler.ForgotPasswordHandler)
+		r.Post("/password/reset", userHandler.ResetPasswordHandler)
+
+		// Public parcel tracking, limited per client IP against scraping of tracking codes
+		r.With(middleware.RateLimit(trackingLimiter)).Get("/track/{trackingCode}", orderHandler.TrackOrder)
+
+		// Routes below require a valid bearer token or API key
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Authenticate(userRepo, revokedTokenRepo, sessionRepo, apiKeyRepo))
+
+			r.With(middleware.RequireScope(repository.ScopeOrdersWrite), middleware.Idempotency(idempotencyKeyRepo)).Post("/orders", orderHandler.CreateOrder)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/all", orderHandler.ListOrders)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/export", orderHandler.ExportOrders)
+			r.With(middleware.RequireScope(repository.ScopeOrdersWrite), middleware.Idempotency(idempotencyKeyRepo)).Post("/orders/batch", orderHandler.CreateOrdersBatch)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/bulk/template", bulkOrderHandler.Template)
+			r.With(middleware.RequireScope(repository.ScopeOrdersWrite)).Post("/orders/bulk", bulkOrderHandler.Upload)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/bulk/{jobID}", bulkOrderHandler.JobStatus)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/by-merchant-id/{merchantOrderID}", orderHandler.GetOrderByMerchantID)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/{consignmentID}", orderHandler.GetOrder)
+			r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/orders/{consignmentID}/timeline", orderHandler.GetOrderTimeline)
+			r.With(middleware.RequireScope(repository.ScopeOrdersWrite)).Patch("/orders/{consignmentID}", orderHandler.UpdateOrder)
+			r.With(middleware.RequireScope(repository.ScopeOrdersWrite)).Put("/orders/{consignmentID}/cancel", orderHandler.CancelOrderHandler)
+
+			// Session and key management need an interactive login
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireBearerToken)
+
+				r.Post("/logout", userHandler.LogoutHandler)
+				r.Post("/logout/all", userHandler.LogoutAllHandler)
+				r.Get("/sessions", userHandler.ListSessionsHandler)
+				r.Delete("/sessions/{sessionID}", userHandler.RevokeSessionHandler)
+				r.Post("/mfa/totp/enroll", userHandler.EnrollTOTPHandler)
+				r.Post("/mfa/totp/confirm", userHandler.ConfirmTOTPHandler)
+				r.Post("/mfa/totp/disable", userHandler.DisableTOTPHandler)
+				r.Post("/api-keys", apiKeyHandler.CreateAPIKey)
+				r.Get("/api-keys", apiKeyHandler.ListAPIKeys)
+				r.Patch("/api-keys/{keyID}", apiKeyHandler.UpdateAPIKey)
+				r.Delete("/api-keys/{keyID}", apiKeyHandler.RevokeAPIKey)
+			})
+
+			// Operations staff only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(repository.RoleOps, repository.RoleAdmin))
 
+				r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/ops/orders", orderHandler.OpsListOrders)
+				r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/ops/orders/{consignmentID}", orderHandler.OpsGetOrder)
+				r.With(middleware.RequireScope(repository.ScopeOrdersRead)).Get("/ops/orders/{consignmentID}/timeline", orderHandler.OpsGetOrderTimeline)
+				r.With(middleware.RequireScope(repository.ScopeOrdersWrite)).Put("/ops/orders/{consignmentID}/status", orderHandler.UpdateOrderStatusHandler)
+				r.With(middleware.RequireBearerToken).Get("/ops/login-attempts", userHandler.ListLoginAttempts)
+			})
+		})
 	})
 
 	// Start the HTTP server
@@ -13,6 +13,18 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+
+	JWTSecretKey string // HMAC secret, required when JWTKeysDir is not set
+	JWTKeysDir   string // Directory of "<kid>.pem" RSA or Ed25519 signing keys
+	JWTActiveKID string // Key new tokens are signed with
+
+	JWTAcceptLegacyHS256 bool // Keep verifying HS256 tokens after switching to JWTKeysDir
+
+	LoginThrottleStore string // Where failed login counters and rate limits are kept: "memory" or "postgres"
+	TrustProxyHeaders  bool   // Take the client IP from X-Forwarded-For / X-Real-IP
+
+	Notifier     string // How notifications are delivered: "log" or "file"
+	NotifierFile string // Destination of the "file" notifier
 }
 
 func LoadConfig() *Config {
@@ -25,5 +37,17 @@ func LoadConfig() *Config {
 		DBUser:     os.Getenv("DB_USER"),
 		DBPassword: os.Getenv("DB_PASSWORD"),
 		DBName:     os.Getenv("DB_NAME"),
+
+		JWTSecretKey: os.Getenv("JWT_SECRET_KEY"),
+		JWTKeysDir:   os.Getenv("JWT_KEYS_DIR"),
+		JWTActiveKID: os.Getenv("JWT_ACTIVE_KID"),
+
+		JWTAcceptLegacyHS256: os.Getenv("JWT_ACCEPT_LEGACY_HS256") == "true",
+
+		LoginThrottleStore: os.Getenv("LOGIN_THROTTLE_STORE"),
+		TrustProxyHeaders:  os.Getenv("TRUST_PROXY_HEADERS") == "true",
+
+		Notifier:     os.Getenv("NOTIFIER"),
+		NotifierFile: os.Getenv("NOTIFIER_FILE"),
 	}
 }
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler handles merchant API key management
+type APIKeyHandler struct {
+	apiKeyRepo *repository.APIKeyRepository
+}
+
+// NewAPIKeyHandler initializes the APIKeyHandler
+func NewAPIKeyHandler(apiKeyRepo *repository.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyRepo: apiKeyRepo}
+}
+
+// APIKeyRequest represents the request body for creating or updating an API key
+type APIKeyRequest struct {
+	Name   *string  `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// validate checks the request and returns field errors; name is required when creating
+func (req *APIKeyRequest) validate(creating bool) map[string][]string {
+	errors := make(map[string][]string)
+
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		req.Name = &trimmed
+	}
+	if (creating && req.Name == nil) || (req.Name != nil && *req.Name == "") {
+		errors["name"] = append(errors["name"], "The name field is required")
+	}
+	if req.Name != nil && len(*req.Name) > 100 {
+		errors["name"] = append(errors["name"], "The name may not be greater than 100 characters")
+	}
+
+	if creating && len(req.Scopes) == 0 {
+		errors["scopes"] = append(errors["scopes"], "At least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			errors["scopes"] = append(errors["scopes"], "Unknown scope "+scope)
+		}
+	}
+	return errors
+}
+
+func validScope(scope string) bool {
+	for _, s := range repository.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey creates an API key; the full key is only ever returned in this response
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var keyRequest APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&keyRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if errs := keyRequest.validate(true); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	apiKey, prefix, secret, err := utils.GenerateAPIKey()
+	if err != nil {
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	key, err := h.apiKeyRepo.Create(user.ID, *keyRequest.Name, prefix, utils.HashToken(secret), keyRequest.Scopes)
+	if err != nil {
+		log.Printf("Failed to create api key: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API Key Created Successfully. Store the key now, it will not be shown again.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"api_key": apiKey,
+			"key":     key,
+		},
+	})
+}
+
+// ListAPIKeys lists the user's API keys, without their secrets
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.apiKeyRepo.ListByUser(user.ID)
+	if err != nil {
+		log.Printf("Failed to fetch api keys: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API keys successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    keys,
+	})
+}
+
+// UpdateAPIKey renames an API key or replaces its scopes
+func (h *APIKeyHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.Atoi(chi.URLParam(r, "keyID"))
+	if err != nil {
+		writeError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	var keyRequest APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&keyRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if errs := keyRequest.validate(false); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	current, err := h.apiKeyRepo.GetByUser(user.ID, keyID)
+	if err != nil {
+		h.writeKeyError(w, err)
+		return
+	}
+
+	name, scopes := current.Name, current.Scopes
+	if keyRequest.Name != nil {
+		name = *keyRequest.Name
+	}
+	if keyRequest.Scopes != nil {
+		scopes = keyRequest.Scopes
+	}
+
+	key, err := h.apiKeyRepo.Update(user.ID, keyID, name, scopes)
+	if err != nil {
+		h.writeKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API Key Updated Successfully",
+		"type":    "success",
+		"code":    200,
+		"data":    key,
+	})
+}
+
+// RevokeAPIKey permanently disables an API key
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.Atoi(chi.URLParam(r, "keyID"))
+	if err != nil {
+		writeError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiKeyRepo.Revoke(user.ID, keyID); err != nil {
+		h.writeKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API Key Revoked Successfully",
+		"type":    "success",
+		"code":    200,
+	})
+}
+
+// writeKeyError maps repository errors to responses
+func (h *APIKeyHandler) writeKeyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		writeError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Failed to update api key: %v", err)
+	writeError(w, "Internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+)
+
+// batchMaxOrders is the most orders accepted in one batch request
+const batchMaxOrders = 500
+
+// Batch modes
+const (
+	batchModeAtomic  = "atomic"  // Nothing is created unless every order is valid
+	batchModePartial = "partial" // Valid orders are created, invalid ones are reported
+)
+
+// BatchOrderRequest represents the request body for creating several orders at once
+type BatchOrderRequest struct {
+	Mode   string         `json:"mode"`
+	Orders []OrderRequest `json:"orders"`
+}
+
+// BatchOrderResult is the outcome of one order of a batch, by its index in the request
+type BatchOrderResult struct {
+	Index           int                 `json:"index"`
+	ConsignmentID   int                 `json:"consignment_id,omitempty"`
+	MerchantOrderID string              `json:"merchant_order_id,omitempty"`
+	TrackingCode    string              `json:"tracking_code,omitempty"`
+	Errors          map[string][]string `json:"errors,omitempty"`
+}
+
+// CreateOrdersBatch handles the POST request for creating up to batchMaxOrders orders in one call.
+// In atomic mode any invalid order fails the whole batch with a 422 whose error keys are
+// "orders.<index>.<field>"; in partial mode the valid orders are created and every order
+// gets a result.
+func (h *OrderHandler) CreateOrdersBatch(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var batchRequest BatchOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&batchRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if batchRequest.Mode == "" {
+		batchRequest.Mode = batchModeAtomic
+	}
+
+	requestErrors := make(map[string][]string)
+	if batchRequest.Mode != batchModeAtomic && batchRequest.Mode != batchModePartial {
+		requestErrors["mode"] = append(requestErrors["mode"], "The mode must be atomic or partial")
+	}
+	if len(batchRequest.Orders) == 0 {
+		requestErrors["orders"] = append(requestErrors["orders"], "At least one order is required")
+	}
+	if len(batchRequest.Orders) > batchMaxOrders {
+		requestErrors["orders"] = append(requestErrors["orders"], fmt.Sprintf("The batch may not contain more than %d orders", batchMaxOrders))
+	}
+	if len(requestErrors) > 0 {
+		writeValidationErrors(w, requestErrors)
+		return
+	}
+
+	results := make([]BatchOrderResult, len(batchRequest.Orders))
+	var (
+		orders       []*repository.Order
+		orderIndex   []int // Index in results of each order in orders
+		orderErrors  = make(map[string][]string)
+		invalidCount int
+		references   = make(map[string]bool) // Merchant order IDs seen so far, per store
+	)
+	for i := range batchRequest.Orders {
+		orderRequest := &batchRequest.Orders[i]
+		results[i] = BatchOrderResult{Index: i, MerchantOrderID: orderRequest.MerchantOrderID}
+
+		errs := orderRequest.validate()
+		if orderRequest.MerchantOrderID != "" {
+			reference := fmt.Sprintf("%d/%s", orderRequest.StoreID, orderRequest.MerchantOrderID)
+			if references[reference] {
+				errs["merchant_order_id"] = append(errs["merchant_order_id"], "The merchant order ID is used more than once for this store in the batch")
+			}
+			references[reference] = true
+		}
+		if len(errs) > 0 {
+			results[i].Errors = errs
+			for field, messages := range errs {
+				orderErrors[fmt.Sprintf("orders.%d.%s", i, field)] = messages
+			}
+			invalidCount++
+			continue
+		}
+
+		order, err := orderRequest.newOrder(user.ID)
+		if err != nil {
+			writeError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		orders = append(orders, order)
+		orderIndex = append(orderIndex, i)
+	}
+
+	if batchRequest.Mode == batchModeAtomic && invalidCount > 0 {
+		writeValidationErrors(w, orderErrors)
+		return
+	}
+
+	// All orders of the batch are inserted by one statement in one transaction. In
+	// partial mode orders whose merchant order ID is already taken are skipped.
+	succeededCount := 0
+	if len(orders) > 0 {
+		consignmentIDs, err := h.orderRepo.CreateOrders(orders, batchRequest.Mode == batchModePartial)
+		if errors.Is(err, repository.ErrDuplicateMerchantOrderID) {
+			writeValidationErrors(w, map[string][]string{
+				"orders": {"One or more merchant order IDs have already been used for their store"},
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to create batch orders: %v", err)
+			writeError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		for i, index := range orderIndex {
+			if consignmentIDs[i] == 0 {
+				results[index].Errors = map[string][]string{"merchant_order_id": {duplicateMerchantOrderIDMessage}}
+				continue
+			}
+			results[index].ConsignmentID = consignmentIDs[i]
+			results[index].TrackingCode = orders[i].TrackingCode
+			succeededCount++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Batch processed.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"mode":      batchRequest.Mode,
+			"total":     len(results),
+			"succeeded": succeededCount,
+			"failed":    len(results) - succeededCount,
+			"results":   results,
+		},
+	})
+}
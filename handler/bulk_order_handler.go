@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	bulkMaxUploadSize = 10 << 20 // Largest accepted CSV file, in bytes
+	bulkMaxRows       = 10000    // Most data rows accepted in one file
+	bulkSyncRowLimit  = 100      // Files with more rows are processed as a background job
+	bulkBatchSize     = 100      // Orders inserted per statement
+)
+
+// bulkColumns are the CSV columns, named after the fields of OrderRequest
+var bulkColumns = []string{
+	"store_id", "merchant_order_id", "recipient_name", "recipient_phone", "recipient_address",
+	"recipient_city", "recipient_zone", "recipient_area", "delivery_type", "item_type",
+	"special_instruction", "item_quantity", "item_weight", "amount_to_collect", "item_description",
+}
+
+// bulkTemplateExample is the sample row of the downloadable template
+var bulkTemplateExample = []string{
+	"1", "INV-1001", "Rahim Uddin", "01712345678", "House 12, Road 5, Dhanmondi, Dhaka",
+	"1", "1", "1", "48", "2", "Call before delivery", "1", "0.5", "1200", "T-shirt",
+}
+
+// bulkRow is a parsed data row of an upload
+type bulkRow struct {
+	Line    int // Line in the file, the header being line 1
+	Request OrderRequest
+	Errors  map[string][]string // Values that could not be parsed
+}
+
+// BulkOrderHandler handles creating orders from CSV uploads
+type BulkOrderHandler struct {
+	orderRepo repository.OrderRepository
+	jobRepo   *repository.BulkOrderJobRepository
+}
+
+// NewBulkOrderHandler initializes the BulkOrderHandler
+func NewBulkOrderHandler(orderRepo repository.OrderRepository, jobRepo *repository.BulkOrderJobRepository) *BulkOrderHandler {
+	return &BulkOrderHandler{orderRepo: orderRepo, jobRepo: jobRepo}
+}
+
+// Template serves a CSV file with the expected header and an example row
+func (h *BulkOrderHandler) Template(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="bulk-orders-template.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write(bulkColumns)
+	writer.Write(bulkTemplateExample)
+	writer.Flush()
+}
+
+// Upload creates an order for every valid row of an uploaded CSV file. Small files are
+// processed right away; larger ones are queued as a job whose status can be polled.
+func (h *BulkOrderHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, bulkMaxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeValidationErrors(w, map[string][]string{"file": {"A CSV file of at most 10 MB is required"}})
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseBulkCSV(file)
+	if err != nil {
+		writeValidationErrors(w, map[string][]string{"file": {err.Error()}})
+		return
+	}
+
+	if len(rows) > bulkSyncRowLimit {
+		jobID, err := h.jobRepo.Create(user.ID, header.Filename, len(rows))
+		if err != nil {
+			log.Printf("Failed to create bulk order job: %v", err)
+			writeError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		go h.runJob(jobID, user.ID, rows)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/orders/bulk/%d", jobID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Bulk upload queued. Check the job status for the results.",
+			"type":    "success",
+			"code":    202,
+			"data": map[string]interface{}{
+				"job_id":     jobID,
+				"status":     repository.BulkJobQueued,
+				"total_rows": len(rows),
+			},
+		})
+		return
+	}
+
+	results, _ := h.createOrders(user.ID, rows, nil)
+	succeeded := 0
+	for _, result := range results {
+		if result.ConsignmentID != 0 {
+			succeeded++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Bulk upload processed.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"total_rows":     len(results),
+			"succeeded_rows": succeeded,
+			"failed_rows":    len(results) - succeeded,
+			"results":        results,
+		},
+	})
+}
+
+// JobStatus reports the progress of a queued upload and the per-row results of the rows done so far
+func (h *BulkOrderHandler) JobStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID, err := strconv.Atoi(chi.URLParam(r, "jobID"))
+	if err != nil {
+		writeError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobRepo.Get(user.ID, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrBulkJobNotFound) {
+			writeError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch bulk order job: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Job successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    job,
+	})
+}
+
+// runJob processes a queued upload in the background
+func (h *BulkOrderHandler) runJob(jobID, userID int, rows []bulkRow) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Bulk order job %d panicked: %v", jobID, p)
+			if err := h.jobRepo.Fail(jobID, "The upload could not be processed"); err != nil {
+				log.Printf("Failed to mark bulk order job %d as failed: %v", jobID, err)
+			}
+		}
+	}()
+
+	// The results are stored after every batch, so that an interrupted job still shows
+	// which orders were created. A job failed as stale in the meantime stops here.
+	results, err := h.createOrders(userID, rows, func(done []repository.BulkRowResult) error {
+		err := h.jobRepo.UpdateProgress(jobID, done)
+		if err != nil && !errors.Is(err, repository.ErrBulkJobNotRunning) {
+			log.Printf("Failed to update bulk order job %d: %v", jobID, err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("Bulk order job %d stopped after %d rows: %v", jobID, len(results), err)
+		return
+	}
+
+	if err := h.jobRepo.Complete(jobID, results); err != nil {
+		log.Printf("Failed to complete bulk order job %d: %v", jobID, err)
+	}
+}
+
+// createOrders validates every row with the rules of CreateOrder and inserts the valid
+// ones in batches. progress, if set, is called with the results of the rows handled so
+// far after every batch; if it returns an error, no further rows are created and the
+// results so far are returned with that error.
+func (h *BulkOrderHandler) createOrders(userID int, rows []bulkRow, progress func([]repository.BulkRowResult) error) ([]repository.BulkRowResult, error) {
+	results := make([]repository.BulkRowResult, len(rows))
+	var (
+		batch      []*repository.Order
+		batchIndex []int // Index in results of each order in batch
+	)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		consignmentIDs, err := h.orderRepo.CreateOrders(batch, true)
+		for i, index := range batchIndex {
+			if err != nil {
+				results[index].Errors = map[string][]string{"row": {"The order could not be created, please retry"}}
+				continue
+			}
+			if consignmentIDs[i] == 0 {
+				results[index].Errors = map[string][]string{"merchant_order_id": {duplicateMerchantOrderIDMessage}}
+				continue
+			}
+			results[index].ConsignmentID = consignmentIDs[i]
+			results[index].TrackingCode = batch[i].TrackingCode
+		}
+		if err != nil {
+			log.Printf("Failed to create bulk orders: %v", err)
+		}
+		batch, batchIndex = nil, nil
+	}
+
+	for i, row := range rows {
+		results[i].Row = row.Line
+
+		errs := row.Request.validate()
+		for field, messages := range row.Errors {
+			// A value that could not be parsed replaces the "required" error it causes
+			errs[field] = messages
+		}
+		if len(errs) > 0 {
+			results[i].Errors = errs
+			continue
+		}
+
+		order, err := row.Request.newOrder(userID)
+		if err != nil {
+			results[i].Errors = map[string][]string{"row": {"The order could not be created, please retry"}}
+			continue
+		}
+		batch = append(batch, order)
+		batchIndex = append(batchIndex, i)
+
+		if len(batch) == bulkBatchSize {
+			flush()
+			if progress != nil {
+				if err := progress(results[:i+1]); err != nil {
+					return results[:i+1], err
+				}
+			}
+		}
+	}
+	flush()
+
+	return results, nil
+}
+
+// parseBulkCSV reads the header and data rows of an upload. Columns are matched by
+// name, so they may come in any order and unknown columns are ignored.
+func parseBulkCSV(file io.Reader) ([]bulkRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("The file must be a CSV file with a header row")
+	}
+
+	positions := make(map[string]int)
+	for i, name := range header {
+		// Spreadsheet exports may start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		positions[name] = i
+	}
+	var missing []string
+	for _, column := range bulkColumns {
+		if _, ok := positions[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("The file is missing the columns: %s", strings.Join(missing, ", "))
+	}
+
+	var rows []bulkRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("The file could not be read: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) == bulkMaxRows {
+			return nil, fmt.Errorf("The file may not contain more than %d rows", bulkMaxRows)
+		}
+
+		value := func(column string) string {
+			if i := positions[column]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, parseBulkRow(line, value))
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("The file does not contain any orders")
+	}
+	return rows, nil
+}
+
+// parseBulkRow converts the values of a row into an order request
+func parseBulkRow(line int, value func(column string) string) bulkRow {
+	row := bulkRow{Line: line, Errors: make(map[string][]string)}
+
+	intValue := func(column string) int {
+		v := value(column)
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			row.Errors[column] = []string{"The " + strings.ReplaceAll(column, "_", " ") + " must be a whole number"}
+		}
+		return n
+	}
+	floatValue := func(column string) float64 {
+		v := value(column)
+		if v == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			row.Errors[column] = []string{"The " + strings.ReplaceAll(column, "_", " ") + " must be a number"}
+		}
+		return f
+	}
+
+	row.Request = OrderRequest{
+		StoreID:            intValue("store_id"),
+		MerchantOrderID:    value("merchant_order_id"),
+		RecipientName:      value("recipient_name"),
+		RecipientPhone:     value("recipient_phone"),
+		RecipientAddress:   value("recipient_address"),
+		RecipientCity:      intValue("recipient_city"),
+		RecipientZone:      intValue("recipient_zone"),
+		RecipientArea:      intValue("recipient_area"),
+		DeliveryType:       intValue("delivery_type"),
+		ItemType:           intValue("item_type"),
+		SpecialInstruction: value("special_instruction"),
+		ItemQuantity:       intValue("item_quantity"),
+		ItemWeight:         floatValue("item_weight"),
+		AmountToCollect:    floatValue("amount_to_collect"),
+		ItemDescription:    value("item_description"),
+	}
+	return row
+}
+
+// isBlankRecord reports whether every field of a CSV record is empty, e.g. a trailing line
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
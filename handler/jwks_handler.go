@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang-orders-app/utils"
+)
+
+// JWKSHandler publishes the public keys tokens are signed with
+type JWKSHandler struct {
+	keySet *utils.KeySet
+}
+
+// NewJWKSHandler initializes the JWKSHandler
+func NewJWKSHandler(keySet *utils.KeySet) *JWKSHandler {
+	return &JWKSHandler{keySet: keySet}
+}
+
+// JWKS serves the key set in JSON Web Key Set format so other services can verify tokens
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": h.keySet.JWKS(),
+	})
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// totpIssuer is the account issuer shown in authenticator apps
+const totpIssuer = "Orders Backend"
+
+// recoveryCodeCount is the number of recovery codes issued when enabling two-factor authentication
+const recoveryCodeCount = 10
+
+// MFALoginRequest represents the request body for completing a login with a second factor
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TOTPCodeRequest represents a request body carrying a TOTP or recovery code
+type TOTPCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// writeMFAChallenge responds to a correct password with a challenge token that must
+// be exchanged at /login/mfa together with a valid code
+func (h *UserHandler) writeMFAChallenge(w http.ResponseWriter, user *repository.User) {
+	mfaToken, err := utils.GenerateMFAToken(user.Username)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Two-factor authentication required",
+		"type":    "mfa_required",
+		"code":    200,
+		"data": map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(utils.MFATokenTTL.Seconds()),
+		},
+	})
+}
+
+// MFALoginHandler exchanges a challenge token and a TOTP or recovery code for an access token
+func (h *UserHandler) MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	var mfaReq MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&mfaReq); err != nil || mfaReq.MFAToken == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := utils.ValidateMFAToken(mfaReq.MFAToken)
+	if err != nil {
+		writeError(w, "The two-factor challenge is invalid or expired. Please log in again.", http.StatusUnauthorized)
+		return
+	}
+	revoked, err := h.RevokedTokenRepo.IsRevoked(claims.ID)
+	if err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		writeError(w, "The two-factor challenge is invalid or expired. Please log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	// Guessing codes is throttled like guessing passwords
+	clientIP := utils.ClientIP(r)
+	wait, err := h.Throttle.Check(claims.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login throttle: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		h.recordLoginAttempt(r, claims.Username, false, "locked")
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByUsername(claims.Username)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.TOTPEnabledAt == nil {
+		writeError(w, "The two-factor challenge is invalid or expired. Please log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := h.checkSecondFactor(user, mfaReq.Code, mfaReq.RecoveryCode)
+	if err != nil {
+		log.Printf("Failed to check second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.recordLoginAttempt(r, claims.Username, false, "invalid_mfa_code")
+		if wait, err := h.Throttle.Failure(claims.Username, clientIP); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		} else if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		}
+		writeError(w, "The two-factor code is incorrect.", http.StatusBadRequest)
+		return
+	}
+
+	// The challenge can only be completed once
+	if err := h.RevokedTokenRepo.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("Failed to revoke challenge token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.recordLoginAttempt(r, claims.Username, true, "success")
+	h.startSession(w, r, user)
+
+	if err := h.Throttle.Success(claims.Username); err != nil {
+		log.Printf("Failed to reset login throttle: %v", err)
+	}
+}
+
+// checkSecondFactor accepts either a TOTP code, which cannot be replayed, or an unused recovery code
+func (h *UserHandler) checkSecondFactor(user *repository.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return h.UserRepo.UseRecoveryCode(user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+
+	step, ok := utils.MatchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return h.UserRepo.UseTOTPStep(user.ID, step)
+}
+
+// EnrollTOTPHandler starts two-factor enrollment by generating a secret for the authenticator app
+func (h *UserHandler) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		writeError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.UserRepo.SetPendingTOTPSecret(user.ID, secret); err != nil {
+		log.Printf("Failed to store totp secret: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Scan the code with your authenticator app, then confirm with a generated code.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"secret":      secret,
+			"otpauth_uri": utils.TOTPURI(totpIssuer, user.Username, secret),
+		},
+	})
+}
+
+// ConfirmTOTPHandler enables two-factor authentication once the user proves the app is set up,
+// and returns the recovery codes, which are never shown again
+func (h *UserHandler) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var codeReq TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&codeReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		writeError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPSecret == "" {
+		writeError(w, "Two-factor enrollment has not been started", http.StatusBadRequest)
+		return
+	}
+
+	step, ok := utils.MatchTOTP(user.TOTPSecret, codeReq.Code, time.Now())
+	if !ok {
+		writeValidationErrors(w, map[string][]string{"code": {"The code is incorrect"}})
+		return
+	}
+	// A code that was already used is refused, like at the login challenge
+	fresh, err := h.UserRepo.UseTOTPStep(user.ID, step)
+	if err != nil {
+		log.Printf("Failed to record totp step: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !fresh {
+		writeValidationErrors(w, map[string][]string{"code": {"The code is incorrect"}})
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	if err := h.UserRepo.EnableTOTP(user.ID, hashes); err != nil {
+		log.Printf("Failed to enable totp: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Two-factor authentication enabled. Store the recovery codes somewhere safe.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTOTPHandler turns off two-factor authentication after checking a current code
+func (h *UserHandler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var codeReq TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&codeReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		writeError(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	// A stolen access token must not allow guessing codes to strip the second factor,
+	// so failures count against the same throttle as the login challenge
+	clientIP := utils.ClientIP(r)
+	wait, err := h.Throttle.Check(user.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login throttle: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	ok, err = h.checkSecondFactor(user, codeReq.Code, codeReq.RecoveryCode)
+	if err != nil {
+		log.Printf("Failed to check second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		if wait, err := h.Throttle.Failure(user.Username, clientIP); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		} else if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		}
+		writeValidationErrors(w, map[string][]string{"code": {"The code is incorrect"}})
+		return
+	}
+
+	if err := h.UserRepo.DisableTOTP(user.ID); err != nil {
+		log.Printf("Failed to disable totp: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Throttle.Success(user.Username); err != nil {
+		log.Printf("Failed to reset login throttle: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Two-factor authentication disabled",
+		"type":    "success",
+		"code":    200,
+	})
+}
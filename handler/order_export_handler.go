@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// Export formats
+const (
+	exportFormatCSV  = "csv"
+	exportFormatXLSX = "xlsx"
+)
+
+// exportFlushRows is how many rows are buffered before they are sent to the client
+const exportFlushRows = 500
+
+// orderExportColumns are the header of an export, named after the fields of repository.OrderAll
+var orderExportColumns = []string{
+	"order_consignment_id", "order_created_at", "order_description", "merchant_order_id",
+	"recipient_name", "recipient_address", "recipient_phone", "order_amount", "order_status",
+	"order_type", "item_type", "instruction", "delivery_fee", "cod_fee", "promo_discount",
+	"discount", "total_fee",
+}
+
+// orderExportRow returns the values of an order in the order of orderExportColumns,
+// the fee breakdown last
+func orderExportRow(order repository.OrderAll) []interface{} {
+	return []interface{}{
+		order.OrderConsignmentID, order.OrderCreatedAt, order.OrderDescription, order.MerchantOrderID,
+		order.RecipientName, order.RecipientAddress, order.RecipientPhone, order.OrderAmount, order.OrderStatus,
+		order.OrderType, order.ItemType, order.Instruction, order.DeliveryFee, order.CODFee, order.PromoDiscount,
+		order.Discount, order.TotalFee,
+	}
+}
+
+// orderExporter writes the rows of an export in one format
+type orderExporter interface {
+	WriteRow(values []interface{}) error
+	Flush() error
+	Close() error
+}
+
+// ExportOrders handles the GET request for downloading the orders matching the filters
+// of ListOrders as a CSV or XLSX file. Rows are streamed from the database to the
+// client as they are read, so exports of any size are never held in memory.
+func (h *OrderHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, filterErrors := parseOrderFilter(query, user.ID)
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = exportFormatCSV
+	}
+	if format != exportFormatCSV && format != exportFormatXLSX {
+		filterErrors["format"] = append(filterErrors["format"], "The format must be csv or xlsx")
+	}
+	if len(filterErrors) > 0 {
+		writeValidationErrors(w, filterErrors)
+		return
+	}
+
+	// The file is only started with the first row, so a failing query can still be
+	// answered with an error status
+	var exporter orderExporter
+	start := func() error {
+		filename := fmt.Sprintf("orders-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if format == exportFormatXLSX {
+			w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+			xlsx, err := utils.NewXLSXWriter(w, "Orders")
+			if err != nil {
+				return err
+			}
+			exporter = xlsx
+		} else {
+			w.Header().Set("Content-Type", "text/csv")
+			exporter = &csvExporter{writer: csv.NewWriter(w)}
+		}
+		return exporter.WriteRow(stringValues(orderExportColumns))
+	}
+
+	written := 0
+	err := h.orderRepo.ExportOrders(filter, func(order repository.OrderAll) error {
+		if exporter == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := exporter.WriteRow(orderExportRow(order)); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushRows == 0 {
+			return exporter.Flush()
+		}
+		return nil
+	})
+	if err == nil && exporter == nil {
+		// No matching orders: the file only has its header
+		err = start()
+	}
+	if err != nil {
+		if exporter == nil {
+			log.Printf("Failed to export orders: %v", err)
+			writeError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Part of the file was sent already; dropping the connection keeps the client
+		// from taking a truncated file for a complete one
+		log.Printf("Failed to export orders after %d rows: %v", written, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if err := exporter.Close(); err != nil {
+		log.Printf("Failed to finish orders export: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// csvExporter writes an export as CSV
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExporter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = csvSafe(v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvExporter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+// csvSafe keeps spreadsheet applications from evaluating merchant-entered text as a
+// formula, by prefixing text that starts like one with a quote
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// stringValues converts strings to the values of a row
+func stringValues(values []string) []interface{} {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		row[i] = v
+	}
+	return row
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-orders-app/model"
+	"golang-orders-app/repository"
+)
+
+// orderFilterDateLayout is the format of the created_from and created_to query parameters
+const orderFilterDateLayout = "2006-01-02"
+
+// parseOrderFilter reads the filters of an order listing from its query parameters.
+// It returns validation errors keyed by parameter for values it cannot use.
+//
+// Supported parameters:
+//   - status: one or more statuses, repeated or comma separated
+//   - transfer_status: legacy filter, "1" for pending orders and anything else for cancelled ones
+//   - archive: true or false
+//   - created_from, created_to: dates (YYYY-MM-DD), both inclusive
+//   - store_id, city, zone, delivery_type: exact matches
+//   - min_amount, max_amount: range of the amount to collect, both inclusive
+//   - search: part of the recipient name or phone, or of the merchant order ID
+//   - sort: a column, prefixed with "-" to sort descending
+func parseOrderFilter(query url.Values, userID int) (repository.OrderFilter, map[string][]string) {
+	filter := repository.OrderFilter{UserID: userID, Sort: repository.DefaultOrderSort}
+	errors := make(map[string][]string)
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if !model.ValidOrderStatus(status) {
+				errors["status"] = append(errors["status"], "The status "+status+" is not a valid order status")
+				continue
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	if transferStatus := query.Get("transfer_status"); transferStatus != "" && len(filter.Statuses) == 0 {
+		if transferStatus == "1" {
+			filter.Statuses = []string{model.StatusPending}
+		} else {
+			filter.Statuses = []string{model.StatusCancelled}
+		}
+	}
+
+	if value := query.Get("archive"); value != "" {
+		archive, err := strconv.ParseBool(value)
+		if err != nil {
+			errors["archive"] = append(errors["archive"], "The archive field must be true or false")
+		} else {
+			filter.Archive = &archive
+		}
+	}
+
+	if value := query.Get("created_from"); value != "" {
+		from, err := time.Parse(orderFilterDateLayout, value)
+		if err != nil {
+			errors["created_from"] = append(errors["created_from"], "The created from date must be in the YYYY-MM-DD format")
+		} else {
+			filter.CreatedFrom = from
+		}
+	}
+	if value := query.Get("created_to"); value != "" {
+		to, err := time.Parse(orderFilterDateLayout, value)
+		if err != nil {
+			errors["created_to"] = append(errors["created_to"], "The created to date must be in the YYYY-MM-DD format")
+		} else {
+			// The whole day is included
+			filter.CreatedTo = to.AddDate(0, 0, 1)
+		}
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		errors["created_to"] = append(errors["created_to"], "The created to date may not be before the created from date")
+	}
+
+	intParam := func(name, label string) int {
+		value := query.Get(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			errors[name] = append(errors[name], "The "+label+" must be a positive whole number")
+			return 0
+		}
+		return n
+	}
+	filter.StoreID = intParam("store_id", "store")
+	filter.City = intParam("city", "city")
+	filter.Zone = intParam("zone", "zone")
+	filter.DeliveryType = intParam("delivery_type", "delivery type")
+
+	amountParam := func(name, label string) *float64 {
+		value := query.Get(name)
+		if value == "" {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			errors[name] = append(errors[name], "The "+label+" must be a number of at least 0")
+			return nil
+		}
+		return &amount
+	}
+	filter.MinAmount = amountParam("min_amount", "minimum amount")
+	filter.MaxAmount = amountParam("max_amount", "maximum amount")
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		errors["max_amount"] = append(errors["max_amount"], "The maximum amount may not be less than the minimum amount")
+	}
+
+	filter.Search = strings.TrimSpace(query.Get("search"))
+
+	if sort := query.Get("sort"); sort != "" {
+		if !repository.ValidOrderSort(sort) {
+			errors["sort"] = append(errors["sort"], "The sort must be created_at, amount_to_collect, total_fee or recipient_name, optionally prefixed with -")
+		} else {
+			filter.Sort = sort
+		}
+	}
+
+	return filter, errors
+}
@@ -2,19 +2,23 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"golang-orders-app/middleware"
 	"golang-orders-app/model"
 	"golang-orders-app/repository"
 	"log"
-	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/go-chi/chi/v5"
 )
 
+// phoneRegex validates Bangladeshi mobile numbers
+var phoneRegex = regexp.MustCompile(`^(01)[3-9]{1}[0-9]{8}$`)
+
 // OrderHandler struct holds the repository for the orders
 type OrderHandler struct {
 	orderRepo repository.OrderRepository
@@ -27,55 +31,17 @@ func NewOrderHandler(orderRepo repository.OrderRepository) *OrderHandler {
 
 // CreateOrder handles the POST request for creating an order
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	// Step 1: Validate JWT Token
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	tokenString = tokenString[len("Bearer "):] // Remove "Bearer " prefix
-
-	// Parse the JWT token to extract user ID
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Verify the token signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		// Return the JWT secret key for verification
-		return []byte("secret"), nil
-	})
-
-	if err != nil || !token.Valid {
+	// Step 1: Resolve the authenticated user
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Extract user ID from the token
-	claims, _ := token.Claims.(jwt.MapClaims)
-	userName := claims["username"].(string)
-	user, err := h.orderRepo.GetUser(userName)
-
 	userID := user.ID
 
 	// Step 2: Parse and Validate Request Body
-	var orderRequest struct {
-		StoreID            int     `json:"store_id"`
-		MerchantOrderID    string  `json:"merchant_order_id"`
-		RecipientName      string  `json:"recipient_name"`
-		RecipientPhone     string  `json:"recipient_phone"`
-		RecipientAddress   string  `json:"recipient_address"`
-		RecipientCity      int     `json:"recipient_city"`
-		RecipientZone      int     `json:"recipient_zone"`
-		RecipientArea      int     `json:"recipient_area"`
-		DeliveryType       int     `json:"delivery_type"`
-		ItemType           int     `json:"item_type"`
-		SpecialInstruction string  `json:"special_instruction"`
-		ItemQuantity       int     `json:"item_quantity"`
-		ItemWeight         float64 `json:"item_weight"`
-		AmountToCollect    float64 `json:"amount_to_collect"`
-		ItemDescription    string  `json:"item_description"`
-	}
+	var orderRequest OrderRequest
 
 	// Decode the JSON request body
 	if err := json.NewDecoder(r.Body).Decode(&orderRequest); err != nil {
@@ -84,108 +50,25 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Step 3: Validate Required Fields
-	errors := make(map[string][]string)
-
-	if orderRequest.StoreID == 0 {
-		errors["store_id"] = append(errors["store_id"], "The store field is required")
-	}
-
-	if orderRequest.RecipientName == "" {
-		errors["recipient_name"] = append(errors["recipient_name"], "The recipient name field is required")
-	}
-
-	phoneRegex := regexp.MustCompile(`^(01)[3-9]{1}[0-9]{8}$`) // BD Number Validation
-	if !phoneRegex.MatchString(orderRequest.RecipientPhone) {
-		errors["recipient_phone"] = append(errors["recipient_phone"], "Invalid phone number")
-	}
-
-	if orderRequest.RecipientAddress == "" {
-		errors["recipient_address"] = append(errors["recipient_address"], "The recipient address field is required")
-	}
-
-	if orderRequest.DeliveryType == 0 {
-		errors["delivery_type"] = append(errors["delivery_type"], "The delivery type field is required")
-	}
-
-	if orderRequest.AmountToCollect == 0 {
-		errors["amount_to_collect"] = append(errors["amount_to_collect"], "The amount to collect field is required")
-	}
-
-	if orderRequest.ItemQuantity == 0 {
-		errors["item_quantity"] = append(errors["item_quantity"], "The item quantity field is required")
-	}
-
-	if orderRequest.ItemWeight == 0 {
-		errors["item_weight"] = append(errors["item_weight"], "The item weight field is required")
-	}
-
-	if orderRequest.ItemType == 0 {
-		errors["item_type"] = append(errors["item_type"], "The item type field is required")
-	}
-
-	if len(errors) > 0 {
+	if errors := orderRequest.validate(); len(errors) > 0 {
 		// Return validation errors
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "Please fix the given errors",
-			"type":    "error",
-			"code":    422,
-			"errors":  errors,
-		})
+		writeValidationErrors(w, errors)
 		return
 	}
 
-	// Calculate Delivery Fee
-	var deliveryFee float64
-	switch {
-	case orderRequest.RecipientCity == 1 && orderRequest.ItemWeight <= 0.5:
-		deliveryFee = 60
-	case orderRequest.RecipientCity == 1 && orderRequest.ItemWeight > 0.5 && orderRequest.ItemWeight <= 1:
-		deliveryFee = 70
-	case orderRequest.RecipientCity == 1 && orderRequest.ItemWeight > 1:
-		deliveryFee = 70 + 15*math.Ceil(orderRequest.ItemWeight-1)
-	default:
-		// If RecipientCity != 1
-		deliveryFee = 100 + 15*math.Ceil(orderRequest.ItemWeight-1)
-	}
-
-	// Calculate COD Fee (1% of AmountToCollect)
-	codFee := orderRequest.AmountToCollect * 0.01
-
-	totalFee := orderRequest.AmountToCollect + codFee + deliveryFee
-
 	// Step 4: Create the Order
-	order := model.Order{
-		UserID:             int(userID),
-		StoreID:            orderRequest.StoreID,
-		MerchantOrderID:    orderRequest.MerchantOrderID,
-		RecipientName:      orderRequest.RecipientName,
-		RecipientPhone:     orderRequest.RecipientPhone,
-		RecipientAddress:   orderRequest.RecipientAddress,
-		RecipientCity:      orderRequest.RecipientCity,
-		RecipientZone:      orderRequest.RecipientZone,
-		RecipientArea:      orderRequest.RecipientArea,
-		DeliveryType:       orderRequest.DeliveryType,
-		ItemType:           orderRequest.ItemType,
-		SpecialInstruction: orderRequest.SpecialInstruction,
-		ItemQuantity:       orderRequest.ItemQuantity,
-		ItemWeight:         orderRequest.ItemWeight,
-		AmountToCollect:    orderRequest.AmountToCollect,
-		ItemDescription:    orderRequest.ItemDescription,
-		OrderTypeID:        1,
-		TotalFee:           totalFee, // Optional field
-		CODFee:             codFee,   // Optional field
-		PromoDiscount:      0.00,     // Optional field
-		Discount:           0.00,     // Optional field
-		DeliveryFee:        deliveryFee,
-		Archive:            false,
-	}
-
-	repoOrder := repository.NewOrderFromModel(&order) // Convert to repository order
+	repoOrder, err := orderRequest.newOrder(userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	// Insert the order into the database
 	consignmentID, err := h.orderRepo.CreateOrder(repoOrder) // Now passing the repository order
+	if errors.Is(err, repository.ErrDuplicateMerchantOrderID) {
+		writeValidationErrors(w, map[string][]string{"merchant_order_id": {duplicateMerchantOrderIDMessage}})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to create order: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -202,58 +85,56 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		"data": map[string]interface{}{
 			"consignment_id":    consignmentID,
 			"merchant_order_id": orderRequest.MerchantOrderID,
-			"order_status":      "Pending",
-			"delivery_fee":      deliveryFee,
+			"order_status":      model.StatusPending,
+			"tracking_code":     repoOrder.TrackingCode,
+			"delivery_fee":      repoOrder.DeliveryFee,
 		},
 	})
 }
 
-// ListOrders handles the GET request for listing orders with pagination and filters.
+// ListOrders handles the GET request for listing orders with pagination, filters and sorting.
+// See parseOrderFilter for the supported query parameters. Pages are numbered unless a
+// cursor, or pagination=cursor for the first page, is given.
 func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	// Step 1: Validate JWT Token
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
+	// Step 1: Resolve the authenticated user
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	tokenString = tokenString[len("Bearer "):] // Remove "Bearer " prefix
+	h.listOrders(w, r, user.ID)
+}
 
-	// Parse the JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, http.ErrAbortHandler
+// OpsListOrders handles the GET request for ops staff listing the orders of every merchant,
+// with the same parameters as ListOrders plus an optional merchant_id.
+func (h *OrderHandler) OpsListOrders(w http.ResponseWriter, r *http.Request) {
+	merchantID := 0
+	if value := r.URL.Query().Get("merchant_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			writeValidationErrors(w, map[string][]string{
+				"merchant_id": {"The merchant must be a positive whole number"},
+			})
+			return
 		}
-		return []byte("secret"), nil
-	})
-	if err != nil || !token.Valid {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Extract user ID from the token
-	claims, _ := token.Claims.(jwt.MapClaims)
-	userName := claims["username"].(string)
-	user, err := h.orderRepo.GetUser(userName)
-	
-	if err != nil {
-		log.Printf("Failed to get users: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		merchantID = id
 	}
 
-	userid := user.ID
+	h.listOrders(w, r, merchantID)
+}
 
+// listOrders responds with the orders of the merchant matching the query parameters; a
+// merchantID of 0 lists every merchant's orders
+func (h *OrderHandler) listOrders(w http.ResponseWriter, r *http.Request, merchantID int) {
 	// Step 2: Extract and validate query parameters
 	query := r.URL.Query()
 
-	transferStatus := query.Get("transfer_status")
-	if transferStatus == "1" {
-		transferStatus = "Pending"
-	} else {
-		transferStatus = "Cancel"
+	filter, filterErrors := parseOrderFilter(query, merchantID)
+	if len(filterErrors) > 0 {
+		writeValidationErrors(w, filterErrors)
+		return
 	}
-	archive := query.Get("archive")
 	limitStr := query.Get("limit")
 	pageStr := query.Get("page")
 
@@ -266,8 +147,14 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 		page = 1 // Default page
 	}
 
+	// Cursor pagination is opted into, the page/limit shape stays the default
+	if cursor := query.Get("cursor"); cursor != "" || query.Get("pagination") == "cursor" {
+		h.listOrdersByCursor(w, filter, cursor, limit)
+		return
+	}
+
 	// Step 3: Call the repository to fetch orders
-	orders, total, err := h.orderRepo.ListOrders(transferStatus, archive, limit, page, userid)
+	orders, total, err := h.orderRepo.ListOrders(filter, limit, page)
 	if err != nil {
 		log.Printf("Failed to fetch orders: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -297,45 +184,349 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// CancelOrderHandler handles the cancellation of an order
-func (h *OrderHandler) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract the consignment ID from the URL path
-	consignmentIDStr := chi.URLParam(r, "consignmentID")
-	consignmentID, err := strconv.Atoi(consignmentIDStr)
+// listOrdersByCursor responds with the page of orders at the cursor, with the cursors of
+// the neighbouring pages in place of page numbers and totals
+func (h *OrderHandler) listOrdersByCursor(w http.ResponseWriter, filter repository.OrderFilter, cursor string, limit int) {
+	page, err := h.orderRepo.ListOrdersByCursor(filter, cursor, limit)
 	if err != nil {
-		http.Error(w, `{"message": "Invalid consignment ID", "type": "error", "code": 400}`, http.StatusBadRequest)
+		switch {
+		case errors.Is(err, repository.ErrCursorSortUnsupported):
+			writeValidationErrors(w, map[string][]string{"sort": {"Cursor pagination only supports sorting by created_at or -created_at"}})
+		case errors.Is(err, repository.ErrInvalidCursor):
+			writeValidationErrors(w, map[string][]string{"cursor": {"The cursor is invalid or does not match the sort"}})
+		default:
+			log.Printf("Failed to fetch orders: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Validate the JWT
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
+	orders := page.Orders
+	if orders == nil {
+		orders = []repository.OrderAll{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Orders successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"data":          orders,
+			"per_page":      limit,
+			"total_in_page": len(orders),
+			"next_cursor":   page.NextCursor,
+			"prev_cursor":   page.PrevCursor,
+		},
+	})
+}
+
+// GetOrder handles the GET request for a single order owned by the user
+func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	tokenString = tokenString[len("Bearer "):] // Remove "Bearer " prefix
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderRepo.GetOrder(user, consignmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch order: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    order,
+	})
+}
+
+// OpsGetOrder handles the GET request for ops staff viewing any merchant's order
+func (h *OrderHandler) OpsGetOrder(w http.ResponseWriter, r *http.Request) {
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
 
-	// Parse the JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, http.ErrAbortHandler
+	order, err := h.orderRepo.GetAnyOrder(consignmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Order not found", http.StatusNotFound)
+			return
 		}
-		return []byte("secret"), nil
+		log.Printf("Failed to fetch order: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    order,
 	})
-	if err != nil || !token.Valid {
+}
+
+// GetOrderByMerchantID handles the GET request for looking up an order by the merchant's own
+// reference. The optional store_id query parameter narrows the lookup to one store.
+func (h *OrderHandler) GetOrderByMerchantID(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	merchantOrderID := chi.URLParam(r, "merchantOrderID")
+	if r.URL.RawPath != "" {
+		// chi routes on the escaped path when the reference contains e.g. an encoded slash
+		unescaped, err := url.PathUnescape(merchantOrderID)
+		if err != nil {
+			writeError(w, "Invalid merchant order ID", http.StatusBadRequest)
+			return
+		}
+		merchantOrderID = unescaped
+	}
+
+	storeID := 0
+	if value := r.URL.Query().Get("store_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			writeError(w, "Invalid store ID", http.StatusBadRequest)
+			return
+		}
+		storeID = id
+	}
+
+	order, err := h.orderRepo.GetOrderByMerchantID(user, storeID, merchantOrderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrMerchantOrderIDAmbiguous) {
+			writeError(w, "The merchant order ID is used by several stores, please specify the store_id", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to fetch order by merchant order ID: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    order,
+	})
+}
+
+// UpdateOrder handles the PATCH request for editing an order before it is picked up
+func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
+
+	var updateRequest OrderUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if errs := updateRequest.immutableFieldErrors(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	// The changes are merged into the order while it is locked, so that edits racing
+	// each other or a status change are applied in turn rather than lost
+	var validationErrors map[string][]string
+	err = h.orderRepo.UpdateOrder(user, consignmentID, func(current *repository.OrderDetail) (*repository.Order, error) {
+		// The edited order must pass the same checks as a new one
+		orderRequest := updateRequest.apply(current)
+		if validationErrors = orderRequest.validate(); len(validationErrors) > 0 {
+			return nil, errInvalidOrderUpdate
+		}
+
+		order := &repository.Order{
+			ID:                 consignmentID,
+			UserID:             user.ID,
+			StoreID:            current.StoreID,
+			MerchantOrderID:    current.MerchantOrderID,
+			RecipientName:      orderRequest.RecipientName,
+			RecipientPhone:     orderRequest.RecipientPhone,
+			RecipientAddress:   orderRequest.RecipientAddress,
+			RecipientCity:      orderRequest.RecipientCity,
+			RecipientZone:      orderRequest.RecipientZone,
+			RecipientArea:      orderRequest.RecipientArea,
+			DeliveryType:       orderRequest.DeliveryType,
+			ItemType:           orderRequest.ItemType,
+			SpecialInstruction: orderRequest.SpecialInstruction,
+			ItemQuantity:       orderRequest.ItemQuantity,
+			ItemWeight:         orderRequest.ItemWeight,
+			AmountToCollect:    orderRequest.AmountToCollect,
+			ItemDescription:    orderRequest.ItemDescription,
+			OrderTypeID:        current.OrderTypeID,
+			TotalFee:           current.TotalFee,
+			CODFee:             current.CODFee,
+			PromoDiscount:      current.PromoDiscount,
+			Discount:           current.Discount,
+			DeliveryFee:        current.DeliveryFee,
+			Archive:            current.Archive,
+		}
+
+		// Fees are only recomputed when an input to them changed
+		if orderRequest.RecipientCity != current.RecipientCity || orderRequest.ItemWeight != current.ItemWeight ||
+			orderRequest.AmountToCollect != current.AmountToCollect {
+			order.DeliveryFee, order.CODFee, order.TotalFee = calculateFees(orderRequest.RecipientCity, orderRequest.ItemWeight, orderRequest.AmountToCollect)
+		}
+		return order, nil
+	})
+	if errors.Is(err, errInvalidOrderUpdate) {
+		writeValidationErrors(w, validationErrors)
+		return
+	}
+	if err != nil {
+		h.writeUpdateError(w, err)
+		return
+	}
+
+	updated, err := h.orderRepo.GetOrder(user, consignmentID)
+	if err != nil {
+		h.writeUpdateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order Updated Successfully",
+		"type":    "success",
+		"code":    200,
+		"data":    updated,
+	})
+}
+
+// errInvalidOrderUpdate aborts an order edit whose result fails validation
+var errInvalidOrderUpdate = errors.New("invalid order update")
+
+// writeUpdateError maps repository errors of an order edit to responses
+func (h *OrderHandler) writeUpdateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		writeError(w, "Order not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrOrderNotEditable):
+		writeError(w, "Only pending orders can be edited", http.StatusConflict)
+	default:
+		log.Printf("Failed to update order: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// GetOrderTimeline handles the GET request for the status history of an order owned by the user
+func (h *OrderHandler) GetOrderTimeline(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.orderRepo.GetOrderTimeline(user, consignmentID)
+	writeTimeline(w, events, err)
+}
+
+// OpsGetOrderTimeline handles the GET request for the status history of any order, with
+// the user who made each change, for ops staff
+func (h *OrderHandler) OpsGetOrderTimeline(w http.ResponseWriter, r *http.Request) {
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.orderRepo.GetAnyOrderTimeline(consignmentID)
+	writeTimeline(w, events, err)
+}
+
+// writeTimeline writes the result of a timeline lookup
+func writeTimeline(w http.ResponseWriter, events []repository.OrderEvent, err error) {
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch order timeline: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order timeline successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    events,
+	})
+}
+
+// CancelOrderHandler handles the cancellation of an order
+func (h *OrderHandler) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Extract the consignment ID from the URL path
+	consignmentIDStr := chi.URLParam(r, "consignmentID")
+	consignmentID, err := strconv.Atoi(consignmentIDStr)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid consignment ID", "type": "error", "code": 400}`, http.StatusBadRequest)
+		return
+	}
+
 	// Cancel the order using the repository
-	err = h.orderRepo.CancelOrder(consignmentID) // Use your repository instance here
+	err = h.orderRepo.CancelOrder(user, consignmentID)
 	if err != nil {
-		if err.Error() == "order already cancelled or not found" {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			http.Error(w, `{"message": "Order not found", "type": "error", "code": 404}`, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrOrderAlreadyCancelled) || errors.Is(err, repository.ErrOrderNotCancellable) {
 			http.Error(w, `{"message": "Please contact cx to cancel order", "type": "error", "code": 400}`, http.StatusBadRequest)
 			return
 		}
 
+		log.Printf("Failed to cancel order: %v", err)
+
 		http.Error(w, `{"message": "Internal server error", "type": "error", "code": 500}`, http.StatusInternalServerError)
 		return
 	}
@@ -349,3 +540,64 @@ func (h *OrderHandler) CancelOrderHandler(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// UpdateOrderStatusHandler lets ops staff move any order along its lifecycle
+func (h *OrderHandler) UpdateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	consignmentID, err := strconv.Atoi(chi.URLParam(r, "consignmentID"))
+	if err != nil {
+		writeError(w, "Invalid consignment ID", http.StatusBadRequest)
+		return
+	}
+
+	var statusRequest struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&statusRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(statusRequest.Status) == "" {
+		writeValidationErrors(w, map[string][]string{
+			"status": {"The status field is required"},
+		})
+		return
+	}
+	if !model.ValidOrderStatus(statusRequest.Status) {
+		writeValidationErrors(w, map[string][]string{
+			"status": {"The selected status is invalid"},
+		})
+		return
+	}
+
+	if err := h.orderRepo.TransitionStatus(user, consignmentID, statusRequest.Status, strings.TrimSpace(statusRequest.Reason)); err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidStatusTransition) {
+			writeError(w, "The order cannot move to "+statusRequest.Status+" from its current status", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to update order status: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order Status Updated Successfully",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"consignment_id": consignmentID,
+			"order_status":   statusRequest.Status,
+		},
+	})
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"math"
+
+	"golang-orders-app/model"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// duplicateMerchantOrderIDMessage is the validation error for a merchant order ID already used for the store
+const duplicateMerchantOrderIDMessage = "The merchant order ID has already been used for this store"
+
+// OrderRequest represents the request body for creating an order
+type OrderRequest struct {
+	StoreID            int     `json:"store_id"`
+	MerchantOrderID    string  `json:"merchant_order_id"`
+	RecipientName      string  `json:"recipient_name"`
+	RecipientPhone     string  `json:"recipient_phone"`
+	RecipientAddress   string  `json:"recipient_address"`
+	RecipientCity      int     `json:"recipient_city"`
+	RecipientZone      int     `json:"recipient_zone"`
+	RecipientArea      int     `json:"recipient_area"`
+	DeliveryType       int     `json:"delivery_type"`
+	ItemType           int     `json:"item_type"`
+	SpecialInstruction string  `json:"special_instruction"`
+	ItemQuantity       int     `json:"item_quantity"`
+	ItemWeight         float64 `json:"item_weight"`
+	AmountToCollect    float64 `json:"amount_to_collect"`
+	ItemDescription    string  `json:"item_description"`
+}
+
+// validate checks the required fields and returns field errors
+func (req *OrderRequest) validate() map[string][]string {
+	errors := make(map[string][]string)
+
+	if req.StoreID == 0 {
+		errors["store_id"] = append(errors["store_id"], "The store field is required")
+	}
+
+	if req.RecipientName == "" {
+		errors["recipient_name"] = append(errors["recipient_name"], "The recipient name field is required")
+	}
+
+	if !phoneRegex.MatchString(req.RecipientPhone) {
+		errors["recipient_phone"] = append(errors["recipient_phone"], "Invalid phone number")
+	}
+
+	if req.RecipientAddress == "" {
+		errors["recipient_address"] = append(errors["recipient_address"], "The recipient address field is required")
+	}
+
+	if req.DeliveryType == 0 {
+		errors["delivery_type"] = append(errors["delivery_type"], "The delivery type field is required")
+	}
+
+	if req.AmountToCollect == 0 {
+		errors["amount_to_collect"] = append(errors["amount_to_collect"], "The amount to collect field is required")
+	}
+
+	if req.ItemQuantity == 0 {
+		errors["item_quantity"] = append(errors["item_quantity"], "The item quantity field is required")
+	}
+
+	if req.ItemWeight == 0 {
+		errors["item_weight"] = append(errors["item_weight"], "The item weight field is required")
+	}
+
+	if req.ItemType == 0 {
+		errors["item_type"] = append(errors["item_type"], "The item type field is required")
+	}
+
+	return errors
+}
+
+// newOrder builds the order to store for the request, with its fees and tracking code
+func (req *OrderRequest) newOrder(userID int) (*repository.Order, error) {
+	// Calculate the delivery, COD and total fees
+	deliveryFee, codFee, totalFee := calculateFees(req.RecipientCity, req.ItemWeight, req.AmountToCollect)
+
+	// Recipients track the parcel with a random code rather than the sequential consignment ID
+	trackingCode, err := utils.GenerateTrackingCode()
+	if err != nil {
+		return nil, err
+	}
+
+	order := model.Order{
+		UserID:             userID,
+		StoreID:            req.StoreID,
+		MerchantOrderID:    req.MerchantOrderID,
+		RecipientName:      req.RecipientName,
+		RecipientPhone:     req.RecipientPhone,
+		RecipientAddress:   req.RecipientAddress,
+		RecipientCity:      req.RecipientCity,
+		RecipientZone:      req.RecipientZone,
+		RecipientArea:      req.RecipientArea,
+		DeliveryType:       req.DeliveryType,
+		ItemType:           req.ItemType,
+		SpecialInstruction: req.SpecialInstruction,
+		ItemQuantity:       req.ItemQuantity,
+		ItemWeight:         req.ItemWeight,
+		AmountToCollect:    req.AmountToCollect,
+		ItemDescription:    req.ItemDescription,
+		OrderTypeID:        1,
+		TotalFee:           totalFee, // Optional field
+		CODFee:             codFee,   // Optional field
+		PromoDiscount:      0.00,     // Optional field
+		Discount:           0.00,     // Optional field
+		DeliveryFee:        deliveryFee,
+		Archive:            false,
+		TrackingCode:       trackingCode,
+	}
+
+	return repository.NewOrderFromModel(&order), nil // Convert to repository order
+}
+
+// calculateFees returns the delivery fee, the COD fee and the total fee of an order
+func calculateFees(recipientCity int, itemWeight, amountToCollect float64) (deliveryFee, codFee, totalFee float64) {
+	// Calculate Delivery Fee
+	switch {
+	case recipientCity == 1 && itemWeight <= 0.5:
+		deliveryFee = 60
+	case recipientCity == 1 && itemWeight > 0.5 && itemWeight <= 1:
+		deliveryFee = 70
+	case recipientCity == 1 && itemWeight > 1:
+		deliveryFee = 70 + 15*math.Ceil(itemWeight-1)
+	default:
+		// If RecipientCity != 1
+		deliveryFee = 100 + 15*math.Ceil(itemWeight-1)
+	}
+
+	// Calculate COD Fee (1% of AmountToCollect)
+	codFee = amountToCollect * 0.01
+
+	totalFee = amountToCollect + codFee + deliveryFee
+	return deliveryFee, codFee, totalFee
+}
+
+// OrderUpdateRequest represents the request body for editing an order; omitted fields are left unchanged
+type OrderUpdateRequest struct {
+	StoreID            *int     `json:"store_id"`
+	MerchantOrderID    *string  `json:"merchant_order_id"`
+	RecipientName      *string  `json:"recipient_name"`
+	RecipientPhone     *string  `json:"recipient_phone"`
+	RecipientAddress   *string  `json:"recipient_address"`
+	RecipientCity      *int     `json:"recipient_city"`
+	RecipientZone      *int     `json:"recipient_zone"`
+	RecipientArea      *int     `json:"recipient_area"`
+	DeliveryType       *int     `json:"delivery_type"`
+	ItemType           *int     `json:"item_type"`
+	SpecialInstruction *string  `json:"special_instruction"`
+	ItemQuantity       *int     `json:"item_quantity"`
+	ItemWeight         *float64 `json:"item_weight"`
+	AmountToCollect    *float64 `json:"amount_to_collect"`
+	ItemDescription    *string  `json:"item_description"`
+}
+
+// immutableFieldErrors rejects fields that cannot change once the order is created
+func (req *OrderUpdateRequest) immutableFieldErrors() map[string][]string {
+	errors := make(map[string][]string)
+	if req.StoreID != nil {
+		errors["store_id"] = append(errors["store_id"], "The store cannot be changed after the order is created")
+	}
+	if req.MerchantOrderID != nil {
+		errors["merchant_order_id"] = append(errors["merchant_order_id"], "The merchant order ID cannot be changed after the order is created")
+	}
+	return errors
+}
+
+// apply returns the current order with the requested changes, as a create request
+func (req *OrderUpdateRequest) apply(current *repository.OrderDetail) OrderRequest {
+	merged := OrderRequest{
+		StoreID:            current.StoreID,
+		MerchantOrderID:    current.MerchantOrderID,
+		RecipientName:      current.RecipientName,
+		RecipientPhone:     current.RecipientPhone,
+		RecipientAddress:   current.RecipientAddress,
+		RecipientCity:      current.RecipientCity,
+		RecipientZone:      current.RecipientZone,
+		RecipientArea:      current.RecipientArea,
+		DeliveryType:       current.DeliveryType,
+		ItemType:           current.ItemType,
+		SpecialInstruction: current.SpecialInstruction,
+		ItemQuantity:       current.ItemQuantity,
+		ItemWeight:         current.ItemWeight,
+		AmountToCollect:    current.AmountToCollect,
+		ItemDescription:    current.ItemDescription,
+	}
+
+	if req.RecipientName != nil {
+		merged.RecipientName = *req.RecipientName
+	}
+	if req.RecipientPhone != nil {
+		merged.RecipientPhone = *req.RecipientPhone
+	}
+	if req.RecipientAddress != nil {
+		merged.RecipientAddress = *req.RecipientAddress
+	}
+	if req.RecipientCity != nil {
+		merged.RecipientCity = *req.RecipientCity
+	}
+	if req.RecipientZone != nil {
+		merged.RecipientZone = *req.RecipientZone
+	}
+	if req.RecipientArea != nil {
+		merged.RecipientArea = *req.RecipientArea
+	}
+	if req.DeliveryType != nil {
+		merged.DeliveryType = *req.DeliveryType
+	}
+	if req.ItemType != nil {
+		merged.ItemType = *req.ItemType
+	}
+	if req.SpecialInstruction != nil {
+		merged.SpecialInstruction = *req.SpecialInstruction
+	}
+	if req.ItemQuantity != nil {
+		merged.ItemQuantity = *req.ItemQuantity
+	}
+	if req.ItemWeight != nil {
+		merged.ItemWeight = *req.ItemWeight
+	}
+	if req.AmountToCollect != nil {
+		merged.AmountToCollect = *req.AmountToCollect
+	}
+	if req.ItemDescription != nil {
+		merged.ItemDescription = *req.ItemDescription
+	}
+	return merged
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang-orders-app/notifier"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// passwordResetTokenTTL is how long a password reset token stays valid
+const passwordResetTokenTTL = time.Hour
+
+// ForgotPasswordRequest represents the request body for the forgot password endpoint
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+// ResetPasswordRequest represents the request body for the reset password endpoint
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPasswordHandler sends a password reset token to the user.
+// It responds the same way whether or not the account exists.
+func (h *UserHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var forgotReq ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&forgotReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	username, _ := normalizeUsername(forgotReq.Username)
+	user, err := h.UserRepo.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Failed to fetch user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user != nil {
+		token, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.UserRepo.CreatePasswordResetToken(user.ID, utils.HashToken(token), time.Now().Add(passwordResetTokenTTL)); err != nil {
+			log.Printf("Failed to store password reset token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		err = h.Notifier.Notify(r.Context(), notifier.Message{
+			To:      user.Username,
+			Subject: "Reset your password",
+			Body:    "Use this token to reset your password: " + token + "\nIt expires in 1 hour. If you did not ask for a reset you can ignore this message.",
+		})
+		if err != nil {
+			log.Printf("Failed to send password reset token: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "If the account exists, a password reset token has been sent.",
+		"type":    "success",
+		"code":    200,
+	})
+}
+
+// ResetPasswordHandler sets a new password using a reset token and logs the user out everywhere
+func (h *UserHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var resetReq ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&resetReq); err != nil || resetReq.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if problems := utils.ValidatePassword(resetReq.Password); len(problems) > 0 {
+		writeValidationErrors(w, map[string][]string{"password": problems})
+		return
+	}
+
+	passwordHash, err := utils.HashPassword(resetReq.Password)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := h.UserRepo.ResetPassword(utils.HashToken(resetReq.Token), passwordHash); err != nil {
+		if errors.Is(err, repository.ErrPasswordResetTokenInvalid) {
+			writeError(w, "The password reset token is invalid or expired.", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to reset password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password reset successfully. Please log in again.",
+		"type":    "success",
+		"code":    200,
+	})
+}
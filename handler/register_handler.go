@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang-orders-app/notifier"
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// verificationTokenTTL is how long a verification token sent after registration stays valid
+const verificationTokenTTL = 24 * time.Hour
+
+// RegisterRequest represents the request body for the registration endpoint
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// VerifyRequest represents the request body for the account verification endpoint
+type VerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest represents the request body for resending a verification token
+type ResendVerificationRequest struct {
+	Username string `json:"username"`
+}
+
+// RegisterHandler creates a merchant account and sends it a verification token
+func (h *UserHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var registerReq RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&registerReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the request
+	errors := make(map[string][]string)
+
+	username, ok := normalizeUsername(registerReq.Username)
+	if !ok {
+		errors["username"] = append(errors["username"], "The username must be a valid email address or phone number")
+	}
+	if problems := utils.ValidatePassword(registerReq.Password); len(problems) > 0 {
+		errors["password"] = problems
+	}
+
+	if len(errors) > 0 {
+		writeValidationErrors(w, errors)
+		return
+	}
+
+	passwordHash, err := utils.HashPassword(registerReq.Password)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := h.UserRepo.CreateUnverifiedUser(username, passwordHash, utils.HashToken(token), time.Now().Add(verificationTokenTTL))
+	if err != nil {
+		if err == repository.ErrUsernameTaken {
+			writeValidationErrors(w, map[string][]string{
+				"username": {"The username has already been taken"},
+			})
+			return
+		}
+		log.Printf("Failed to register user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The account exists even if delivery fails, the token can be resent
+	if err := h.sendVerification(r.Context(), username, token); err != nil {
+		log.Printf("Failed to send verification token: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Registration successful. Please verify your account.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"user_id":  userID,
+			"username": username,
+		},
+	})
+}
+
+// VerifyHandler verifies an account with the token sent after registration
+func (h *UserHandler) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var verifyReq VerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&verifyReq); err != nil || verifyReq.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.UserRepo.VerifyUser(utils.HashToken(verifyReq.Token)); err != nil {
+		if errors.Is(err, repository.ErrVerificationTokenInvalid) {
+			writeError(w, "The verification token is invalid or expired.", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to verify user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Account verified successfully",
+		"type":    "success",
+		"code":    200,
+	})
+}
+
+// ResendVerificationHandler sends a new verification token to an unverified account.
+// It responds the same way whether or not the account exists.
+func (h *UserHandler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var resendReq ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&resendReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	username, _ := normalizeUsername(resendReq.Username)
+	user, err := h.UserRepo.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Failed to fetch user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user != nil && user.VerifiedAt == nil {
+		token, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.UserRepo.CreateVerificationToken(user.ID, utils.HashToken(token), time.Now().Add(verificationTokenTTL)); err != nil {
+			log.Printf("Failed to store verification token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.sendVerification(r.Context(), user.Username, token); err != nil {
+			log.Printf("Failed to send verification token: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "If the account exists and is not verified, a new verification token has been sent.",
+		"type":    "success",
+		"code":    200,
+	})
+}
+
+// sendVerification delivers a verification token to the user
+func (h *UserHandler) sendVerification(ctx context.Context, username, token string) error {
+	return h.Notifier.Notify(ctx, notifier.Message{
+		To:      username,
+		Subject: "Verify your account",
+		Body:    "Use this token to verify your account: " + token + "\nIt expires in 24 hours.",
+	})
+}
+
+// normalizeUsername checks that the username is an email address or phone number
+// and returns it in the form it is stored in
+func normalizeUsername(username string) (string, bool) {
+	username = strings.TrimSpace(username)
+	if phoneRegex.MatchString(username) {
+		return username, true
+	}
+
+	username = strings.ToLower(username)
+	addr, err := mail.ParseAddress(username)
+	if err != nil || addr.Address != username {
+		return username, false
+	}
+	return username, true
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeError writes an error in the API's standard response envelope
+func writeError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"type":    "error",
+		"code":    code,
+	})
+}
+
+// writeValidationErrors writes field validation errors as a 422 response
+func writeValidationErrors(w http.ResponseWriter, errors map[string][]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Please fix the given errors",
+		"type":    "error",
+		"code":    422,
+		"errors":  errors,
+	})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"golang-orders-app/middleware"
+	"golang-orders-app/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSessionsHandler lists the devices the user is currently logged in on
+func (h *UserHandler) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.SessionRepo.ListActive(user.ID)
+	if err != nil {
+		log.Printf("Failed to fetch sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Sessions successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    sessions,
+	})
+}
+
+// RevokeSessionHandler logs the user out of one session, e.g. a leaked or forgotten device.
+// Access tokens of the session stop working immediately and its refresh token is revoked.
+func (h *UserHandler) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.SessionRepo.Revoke(user.ID, chi.URLParam(r, "sessionID")); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			writeError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to revoke session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Session Revoked Successfully",
+		"type":    "success",
+		"code":    200,
+	})
+}
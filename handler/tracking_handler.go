@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang-orders-app/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TrackOrder handles the public GET request recipients use to follow their parcel.
+// Only the status timeline and masked recipient details are shown.
+func (h *OrderHandler) TrackOrder(w http.ResponseWriter, r *http.Request) {
+	trackingCode := strings.ToUpper(strings.TrimSpace(chi.URLParam(r, "trackingCode")))
+
+	tracking, err := h.orderRepo.GetTracking(trackingCode)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeError(w, "Tracking code not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch tracking: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tracking.RecipientName = maskName(tracking.RecipientName)
+	tracking.RecipientPhone = maskPhone(tracking.RecipientPhone)
+	tracking.RecipientAddress = maskAddress(tracking.RecipientAddress)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tracking successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data":    tracking,
+	})
+}
+
+// maskName keeps the first letter of each word, e.g. "Rahim Uddin" becomes "R**** U****"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		runes := []rune(word)
+		words[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+// maskPhone keeps the operator prefix and the last three digits, e.g. "017*****678"
+func maskPhone(phone string) string {
+	if len(phone) <= 6 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-6) + phone[len(phone)-3:]
+}
+
+// maskAddress only keeps the last part of the address, usually the area or city
+func maskAddress(address string) string {
+	parts := strings.Split(address, ",")
+	if len(parts) < 2 {
+		return "***"
+	}
+	return "***, " + strings.TrimSpace(parts[len(parts)-1])
+}
@@ -2,10 +2,17 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
+	"golang-orders-app/middleware"
+	"golang-orders-app/notifier"
 	"golang-orders-app/repository"
+	"golang-orders-app/throttle"
 	"golang-orders-app/utils"
 )
 
@@ -15,6 +22,14 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// refreshTokenTTL is how long a refresh token can be exchanged for a new token pair
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshRequest represents the request body for the token refresh endpoint
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // LoginResponse represents the response body for the login endpoint
 type LoginResponse struct {
 	TokenType    string `json:"token_type"`
@@ -25,12 +40,29 @@ type LoginResponse struct {
 
 // UserHandler represents the handler for user endpoints
 type UserHandler struct {
-	UserRepo *repository.UserRepository
+	UserRepo         *repository.UserRepository
+	RefreshTokenRepo *repository.RefreshTokenRepository
+	RevokedTokenRepo *repository.RevokedTokenRepository
+	SessionRepo      *repository.SessionRepository
+	LoginAttemptRepo *repository.LoginAttemptRepository
+	Throttle         *throttle.Throttle
+	Notifier         notifier.Notifier
 }
 
 // NewUserHandler initializes and returns a new UserHandler
-func NewUserHandler(userRepo *repository.UserRepository) *UserHandler {
-	return &UserHandler{UserRepo: userRepo}
+func NewUserHandler(userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository,
+	revokedTokenRepo *repository.RevokedTokenRepository, sessionRepo *repository.SessionRepository,
+	loginAttemptRepo *repository.LoginAttemptRepository, loginThrottle *throttle.Throttle,
+	notifier notifier.Notifier) *UserHandler {
+	return &UserHandler{
+		UserRepo:         userRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+		RevokedTokenRepo: revokedTokenRepo,
+		SessionRepo:      sessionRepo,
+		LoginAttemptRepo: loginAttemptRepo,
+		Throttle:         loginThrottle,
+		Notifier:         notifier,
+	}
 }
 
 // LoginHandler processes login requests
@@ -41,13 +73,38 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Usernames are stored trimmed and lowercased, so "Alice@Example.com " must find
+	// the account and share its failure counter
+	username, _ := normalizeUsername(loginReq.Username)
+
+	// Refuse attempts while the username or client IP is locked out
+	clientIP := utils.ClientIP(r)
+	wait, err := h.Throttle.Check(username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login throttle: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		h.recordLoginAttempt(r, username, false, "locked")
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
 	// Fetch user from database
-	user, err := h.UserRepo.GetUserByUsername(loginReq.Username)
+	user, err := h.UserRepo.GetUserByUsername(username)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	if user == nil || user.Password != loginReq.Password {
+	if !h.verifyPassword(user, loginReq.Password) {
+		h.recordLoginAttempt(r, username, false, "invalid_credentials")
+		if wait, err := h.Throttle.Failure(username, clientIP); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		} else if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		}
+
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"message": "The user credentials were incorrect.",
@@ -57,8 +114,193 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Self-registered accounts must be verified first
+	if user.VerifiedAt == nil {
+		h.recordLoginAttempt(r, username, false, "unverified")
+		writeError(w, "Please verify your account before logging in.", http.StatusForbidden)
+		return
+	}
+
+	// Accounts with two-factor authentication get a challenge instead of tokens
+	if user.TOTPEnabledAt != nil {
+		h.recordLoginAttempt(r, username, false, "mfa_required")
+		h.writeMFAChallenge(w, user)
+		return
+	}
+
+	h.recordLoginAttempt(r, username, true, "success")
+	h.startSession(w, r, user)
+
+	// Only a completed login clears the failure counter: a correct password followed by
+	// a two-factor challenge must not, or the code could be guessed without limit
+	if err := h.Throttle.Success(username); err != nil {
+		log.Printf("Failed to reset login throttle: %v", err)
+	}
+}
+
+// startSession records a new session for a login, starting its refresh token family,
+// and writes the token pair
+func (h *UserHandler) startSession(w http.ResponseWriter, r *http.Request, user *repository.User) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	session := repository.Session{
+		ID:        familyID,
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		IPAddress: utils.ClientIP(r),
+	}
+	if err := h.SessionRepo.Start(session, utils.HashToken(refreshToken), time.Now().Add(refreshTokenTTL)); err != nil {
+		log.Printf("Failed to start session: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeTokenResponse(w, user, familyID, refreshToken)
+}
+
+// recordLoginAttempt writes the attempt to the audit table; failures are only logged
+func (h *UserHandler) recordLoginAttempt(r *http.Request, username string, success bool, reason string) {
+	err := h.LoginAttemptRepo.Record(repository.LoginAttempt{
+		Username:  username,
+		IPAddress: utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// writeTooManyAttempts tells a locked out client when it may try again
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(wait))
+	writeError(w, "Too many failed login attempts. Please try again later.", http.StatusTooManyRequests)
+}
+
+// retryAfterSeconds formats a wait for the Retry-After header, rounding up
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// ListLoginAttempts lets ops staff audit login attempts by username and IP
+func (h *UserHandler) ListLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 10 // Default limit
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1 // Default page
+	}
+
+	attempts, total, err := h.LoginAttemptRepo.List(query.Get("username"), query.Get("ip"), limit, page)
+	if err != nil {
+		log.Printf("Failed to fetch login attempts: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Login attempts successfully fetched.",
+		"type":    "success",
+		"code":    200,
+		"data": map[string]interface{}{
+			"data":          attempts,
+			"total":         total,
+			"current_page":  page,
+			"per_page":      limit,
+			"total_in_page": len(attempts),
+			"last_page":     (total + limit - 1) / limit,
+		},
+	})
+}
+
+// verifyPassword checks the password against the user's stored credentials, upgrading
+// a legacy plaintext password to a hash the first time it is used successfully
+func (h *UserHandler) verifyPassword(user *repository.User, password string) bool {
+	if user == nil {
+		utils.BurnPasswordCheck(password)
+		return false
+	}
+	if user.PasswordHash != "" {
+		return utils.CheckPassword(user.PasswordHash, password)
+	}
+	if user.Password == "" || !utils.CheckLegacyPassword(user.Password, password) {
+		return false
+	}
+
+	// The login still succeeds if the upgrade fails, it is retried on the next login
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to hash legacy password: %v", err)
+		return true
+	}
+	if err := h.UserRepo.SetPasswordHash(user.ID, hash); err != nil {
+		log.Printf("Failed to upgrade legacy password: %v", err)
+	}
+	return true
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access and refresh token pair
+func (h *UserHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var refreshReq RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refreshReq); err != nil || refreshReq.RefreshToken == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	// Rotate the presented token, the old one can never be exchanged again
+	rotated, err := h.RefreshTokenRepo.Rotate(utils.HashToken(refreshReq.RefreshToken), utils.HashToken(refreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			log.Printf("Refresh token reuse detected, token family revoked")
+		}
+		if errors.Is(err, repository.ErrRefreshTokenInvalid) || errors.Is(err, repository.ErrRefreshTokenReused) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "The refresh token is invalid or expired.",
+				"type":    "error",
+				"code":    401,
+			})
+			return
+		}
+		log.Printf("Failed to rotate refresh token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(rotated.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeTokenResponse(w, user, rotated.FamilyID, refreshToken)
+}
+
+// writeTokenResponse issues an access token for the user and writes it alongside the refresh token
+func (h *UserHandler) writeTokenResponse(w http.ResponseWriter, user *repository.User, familyID, refreshToken string) {
 	// Generate JWT Token
-	accessToken, err := utils.GenerateJWT(user.Username)
+	accessToken, err := utils.GenerateJWT(user.Username, user.Role, familyID)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
@@ -67,9 +309,9 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Create response
 	response := LoginResponse{
 		TokenType:    "Bearer",
-		ExpiresIn:    432000, // 5 days in seconds
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
 		AccessToken:  accessToken,
-		RefreshToken: "REFRESH_TOKEN", // Static for now
+		RefreshToken: refreshToken,
 	}
 
 	// Send response
@@ -79,29 +321,32 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 // LogoutHandler processes logout requests
 func (h *UserHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the token from the Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-
-	// Extract token from the 'Bearer' prefix
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	if tokenString == "" {
-		http.Error(w, "Missing token", http.StatusUnauthorized)
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Validate the token
-	_, err := utils.ValidateToken(tokenString)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Revoke the presented access token until it expires
+	if err := h.RevokedTokenRepo.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("Failed to revoke token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Invalidate the token (this would usually involve blacklisting the token)
-	// Since JWT is stateless, the client needs to remove the token manually. Here, we just acknowledge the logout.
+	// End the session the token belongs to, so its refresh token cannot mint new access tokens
+	if claims.SessionID != "" {
+		if err := h.SessionRepo.Revoke(user.ID, claims.SessionID); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+			log.Printf("Failed to revoke session: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	// Respond with success message
 	response := map[string]interface{}{
@@ -112,8 +357,33 @@ func (h *UserHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(response)
+	err := json.NewEncoder(w).Encode(response)
 	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
+
+// LogoutAllHandler revokes every access and refresh token and every API key of the user
+func (h *UserHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.UserRepo.RevokeAllAccess(user.ID); err != nil {
+		log.Printf("Failed to log out all sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"message": "Successfully logged out of all sessions",
+		"type":    "success",
+		"code":    200,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
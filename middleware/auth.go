@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+// contextKey is the type used for values this package stores in a request context
+type contextKey string
+
+const (
+	userContextKey   contextKey = "user"
+	claimsContextKey contextKey = "claims"
+	apiKeyContextKey contextKey = "api_key"
+)
+
+// errUnauthenticated is returned when the credentials on a request are missing or invalid
+var errUnauthenticated = errors.New("unauthenticated")
+
+// Authenticate validates the credentials of every request, loads the matching
+// user and stores it in the request context for the handlers down the chain.
+// It accepts "Authorization: Bearer <jwt>" and "Authorization: ApiKey <key>".
+// Revoked tokens, tokens of revoked sessions and tokens issued before the user
+// logged out everywhere are rejected.
+func Authenticate(userRepo *repository.UserRepository, revokedTokenRepo *repository.RevokedTokenRepository,
+	sessionRepo *repository.SessionRepository, apiKeyRepo *repository.APIKeyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, credentials, ok := authorization(r)
+			if !ok {
+				writeError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var (
+				ctx  context.Context
+				user *repository.User
+				err  error
+			)
+			switch {
+			case strings.EqualFold(scheme, "Bearer"):
+				var claims *utils.Claims
+				user, claims, err = authenticateToken(userRepo, revokedTokenRepo, sessionRepo, credentials)
+				if err == nil {
+					ctx = context.WithValue(r.Context(), claimsContextKey, claims)
+				}
+			case strings.EqualFold(scheme, "ApiKey"):
+				var key *repository.APIKey
+				user, key, err = authenticateAPIKey(userRepo, apiKeyRepo, credentials)
+				if err == nil {
+					ctx = context.WithValue(r.Context(), apiKeyContextKey, key)
+				}
+			default:
+				err = errUnauthenticated
+			}
+
+			if err != nil {
+				if errors.Is(err, errUnauthenticated) {
+					writeError(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				log.Printf("Failed to authenticate request: %v", err)
+				writeError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			ctx = context.WithValue(ctx, userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticateToken resolves the user of a JWT access token
+func authenticateToken(userRepo *repository.UserRepository, revokedTokenRepo *repository.RevokedTokenRepository,
+	sessionRepo *repository.SessionRepository, tokenString string) (*repository.User, *utils.Claims, error) {
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, errUnauthenticated
+	}
+
+	revoked, err := revokedTokenRepo.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, errUnauthenticated
+	}
+
+	// Tokens issued before sessions were tracked carry no session ID
+	if claims.SessionID != "" {
+		active, err := sessionRepo.CheckActive(claims.SessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !active {
+			return nil, nil, errUnauthenticated
+		}
+	}
+
+	user, err := userRepo.GetUserByUsername(claims.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || issuedBeforeRevocation(claims, user) {
+		return nil, nil, errUnauthenticated
+	}
+	return user, claims, nil
+}
+
+// authenticateAPIKey resolves the user of a merchant API key
+func authenticateAPIKey(userRepo *repository.UserRepository, apiKeyRepo *repository.APIKeyRepository,
+	apiKey string) (*repository.User, *repository.APIKey, error) {
+	prefix, secret, ok := utils.SplitAPIKey(apiKey)
+	if !ok {
+		return nil, nil, errUnauthenticated
+	}
+
+	key, err := apiKeyRepo.GetActiveByPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(utils.HashToken(secret))) != 1 {
+		return nil, nil, errUnauthenticated
+	}
+
+	user, err := userRepo.GetUserByID(key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errUnauthenticated
+	}
+
+	if err := apiKeyRepo.Touch(key.ID); err != nil {
+		log.Printf("Failed to record api key usage: %v", err)
+	}
+	return user, key, nil
+}
+
+// UserFromContext returns the user stored by Authenticate, if any
+func UserFromContext(ctx context.Context) (*repository.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*repository.User)
+	return user, ok && user != nil
+}
+
+// ClaimsFromContext returns the validated token claims stored by Authenticate,
+// if the request was authenticated with a bearer token
+func ClaimsFromContext(ctx context.Context) (*utils.Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*utils.Claims)
+	return claims, ok && claims != nil
+}
+
+// APIKeyFromContext returns the API key stored by Authenticate, if the request
+// was authenticated with one
+func APIKeyFromContext(ctx context.Context) (*repository.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*repository.APIKey)
+	return key, ok && key != nil
+}
+
+// issuedBeforeRevocation reports whether the token may predate the user's last "log out all
+// sessions". The iat claim only has whole seconds, so a token issued in the same second as
+// the revocation is rejected too: it may have been refreshed just before it. A legitimate
+// login in that second has to log in again.
+func issuedBeforeRevocation(claims *utils.Claims, user *repository.User) bool {
+	if user.TokensRevokedAt == nil {
+		return false
+	}
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(user.TokensRevokedAt.Truncate(time.Second))
+}
+
+// authorization splits an "Authorization: <scheme> <credentials>" header
+func authorization(r *http.Request) (scheme, credentials string, ok bool) {
+	scheme, credentials, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found {
+		return "", "", false
+	}
+
+	credentials = strings.TrimSpace(credentials)
+	return scheme, credentials, credentials != ""
+}
+
+// writeError writes an error in the API's standard response envelope
+func writeError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"type":    "error",
+		"code":    code,
+	})
+}
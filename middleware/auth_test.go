@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestIssuedBeforeRevocation(t *testing.T) {
+	revokedAt := time.Date(2024, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt *time.Time
+		revoked  *time.Time
+		want     bool
+	}{
+		{"never revoked", timePtr(revokedAt.Add(-time.Hour)), nil, false},
+		{"issued a second before", timePtr(revokedAt.Add(-time.Second).Truncate(time.Second)), &revokedAt, true},
+		// iat has whole seconds, so a token of the revocation's second may predate it
+		{"issued in the same second", timePtr(revokedAt.Truncate(time.Second)), &revokedAt, true},
+		{"issued the next second", timePtr(revokedAt.Add(time.Second).Truncate(time.Second)), &revokedAt, false},
+		{"no issued at", nil, &revokedAt, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &utils.Claims{}
+			if tt.issuedAt != nil {
+				claims.IssuedAt = jwt.NewNumericDate(*tt.issuedAt)
+			}
+			user := &repository.User{TokensRevokedAt: tt.revoked}
+			if got := issuedBeforeRevocation(claims, user); got != tt.want {
+				t.Errorf("issuedBeforeRevocation = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"golang-orders-app/repository"
+	"golang-orders-app/utils"
+)
+
+const (
+	// IdempotencyKeyTTL is how long a response is replayed for repeats of its key
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyClaimLease is how long a request may hold its key without completing;
+	// after that the key is taken over, so a request lost in a crash does not block
+	// retries for the whole TTL
+	IdempotencyClaimLease = 5 * time.Minute
+
+	idempotencyKeyMaxLength = 255
+	idempotencyMaxBodySize  = 1 << 20
+)
+
+// Idempotency makes retries of a request carrying an "Idempotency-Key" header safe.
+// The first request with a key runs normally and its response is stored; repeats of
+// the same request replay that response instead of running again. Reusing a key for
+// a different request, or while the first one is still running, is answered with 409.
+// A first request that has not completed within IdempotencyClaimLease is taken to be
+// lost, and the next identical repeat runs in its place.
+// Server errors are not stored, so the request can be retried with the same key.
+// It must run after Authenticate, as keys are scoped to the user.
+func Idempotency(idempotencyKeyRepo *repository.IdempotencyKeyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotencyKeyMaxLength {
+				writeError(w, "The Idempotency-Key header may not be longer than 255 characters", http.StatusBadRequest)
+				return
+			}
+
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				writeError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxBodySize+1))
+			if err != nil {
+				writeError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > idempotencyMaxBodySize {
+				writeError(w, "The request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			requestHash := hashRequest(r, body)
+
+			// The claim token keeps this request from touching the key once another
+			// request has taken it over after the lease
+			claimToken, err := utils.GenerateRandomToken(16)
+			if err != nil {
+				log.Printf("Failed to generate idempotency claim token: %v", err)
+				writeError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			claimed, err := idempotencyKeyRepo.Claim(user.ID, key, requestHash, claimToken, time.Now().Add(IdempotencyKeyTTL), IdempotencyClaimLease)
+			if err != nil {
+				log.Printf("Failed to claim idempotency key: %v", err)
+				writeError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !claimed {
+				replayResponse(w, idempotencyKeyRepo, user.ID, key, requestHash)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// A failed or panicking request gives the key back for a retry
+				if !completed {
+					if err := idempotencyKeyRepo.Release(user.ID, key, claimToken); err != nil {
+						log.Printf("Failed to release idempotency key: %v", err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+			// The request took effect, so the key is never released from here on: if the
+			// response cannot be stored, repeats get a 409 until the key expires rather
+			// than running the request again
+			completed = true
+			err = idempotencyKeyRepo.Complete(user.ID, key, claimToken, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+			if err != nil {
+				log.Printf("Failed to store idempotent response: %v", err)
+			}
+		})
+	}
+}
+
+// replayResponse answers a repeat of a key with the stored response, or a conflict
+func replayResponse(w http.ResponseWriter, idempotencyKeyRepo *repository.IdempotencyKeyRepository, userID int, key, requestHash string) {
+	stored, err := idempotencyKeyRepo.Get(userID, key)
+	if err != nil {
+		log.Printf("Failed to fetch idempotency key: %v", err)
+		writeError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case stored == nil:
+		// Released by a failed first request between our claim and this read
+		writeError(w, "The request with this Idempotency-Key failed, please retry", http.StatusConflict)
+	case stored.RequestHash != requestHash:
+		writeError(w, "The Idempotency-Key was already used for a different request", http.StatusConflict)
+	case stored.StatusCode == 0:
+		writeError(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+	default:
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.StatusCode)
+		w.Write(stored.ResponseBody)
+	}
+}
+
+// hashRequest identifies a request by its method, path and body
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
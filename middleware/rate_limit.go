@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"golang-orders-app/throttle"
+	"golang-orders-app/utils"
+)
+
+// RateLimit rejects clients that exceed the limiter's request rate, keyed by client IP
+func RateLimit(limiter *throttle.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wait, err := limiter.Allow(utils.ClientIP(r))
+			if err != nil {
+				log.Printf("Failed to check rate limit: %v", err)
+				writeError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// RequireRole only lets through users that have one of the given roles.
+// It must be mounted after Authenticate.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				writeError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// The role is read from the database, so a role change applies immediately
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			writeError(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
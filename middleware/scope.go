@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// RequireScope only lets API key requests through if the key has been granted the scope.
+// Bearer token requests act with the user's full permissions and always pass.
+// It must be mounted after Authenticate.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, granted := range key.Scopes {
+				if granted == scope {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			writeError(w, "The API key is missing the "+scope+" scope", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireBearerToken rejects requests authenticated with an API key, for routes
+// such as key management that need an interactive login.
+// It must be mounted after Authenticate.
+func RequireBearerToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); !ok {
+			writeError(w, "This endpoint requires a bearer token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
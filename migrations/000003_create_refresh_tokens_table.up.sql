@@ -0,0 +1,14 @@
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Owner of the token
+    family_id VARCHAR(64) NOT NULL,                    -- Shared by every token rotated from the same login
+    token_hash VARCHAR(64) NOT NULL UNIQUE,            -- SHA-256 of the opaque token, the token itself is never stored
+    expires_at TIMESTAMP NOT NULL,                     -- Token cannot be used after this time
+    revoked_at TIMESTAMP,                              -- Set when the token is rotated out or its family is revoked
+    replaced_by INT,                                   -- Token issued when this one was rotated
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the token was issued
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (replaced_by) REFERENCES refresh_tokens (id)
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
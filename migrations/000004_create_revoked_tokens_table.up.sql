@@ -0,0 +1,12 @@
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,                       -- ID of the revoked access token
+    user_id INT NOT NULL,                              -- Owner of the token
+    expires_at TIMESTAMP NOT NULL,                     -- Row can be pruned once the token has expired anyway
+    revoked_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the token was revoked
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+-- Access tokens issued before this time are rejected ("log out all sessions")
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Hashed passwords cannot be recovered, those users must reset their password
+UPDATE users SET password = '' WHERE password IS NULL;
+ALTER TABLE users ALTER COLUMN password SET NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
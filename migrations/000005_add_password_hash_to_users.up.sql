@@ -0,0 +1,5 @@
+-- bcrypt hash of the password, legacy plaintext rows are upgraded on their next login
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(255);
+
+-- Plaintext passwords are cleared once hashed
+ALTER TABLE users ALTER COLUMN password DROP NOT NULL;
@@ -0,0 +1,15 @@
+-- Accounts created through self-service registration must be verified before login
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+
+-- Accounts that existed before registration was introduced are trusted
+UPDATE users SET verified_at = NOW();
+
+CREATE TABLE verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Account the token verifies
+    token_hash VARCHAR(64) NOT NULL UNIQUE,            -- SHA-256 of the token sent to the user
+    expires_at TIMESTAMP NOT NULL,                     -- Token cannot be used after this time
+    used_at TIMESTAMP,                                 -- Set once the token has been used
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the token was issued
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
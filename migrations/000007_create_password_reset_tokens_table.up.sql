@@ -0,0 +1,9 @@
+CREATE TABLE password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Account whose password can be reset
+    token_hash VARCHAR(64) NOT NULL UNIQUE,            -- SHA-256 of the token sent to the user
+    expires_at TIMESTAMP NOT NULL,                     -- Token cannot be used after this time
+    used_at TIMESTAMP,                                 -- Set once the token has been used
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the token was issued
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,3 @@
+-- Role decides which routes a user may call
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'merchant'
+    CHECK (role IN ('merchant', 'ops', 'admin', 'rider'));
@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Merchant the key acts as
+    name VARCHAR(100) NOT NULL,                        -- Label chosen by the merchant
+    prefix VARCHAR(16) NOT NULL UNIQUE,                -- Public part of the key, used to look it up
+    secret_hash VARCHAR(64) NOT NULL,                  -- SHA-256 of the secret part, the secret itself is never stored
+    scopes TEXT[] NOT NULL DEFAULT '{}',               -- Operations the key is allowed to perform
+    last_used_at TIMESTAMP,                            -- Timestamp of the last authenticated request
+    revoked_at TIMESTAMP,                              -- Set when the key is revoked
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the key was created
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,20 @@
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,                    -- Username as submitted, the account may not exist
+    ip_address VARCHAR(64) NOT NULL,                   -- Client IP the attempt came from
+    user_agent TEXT,                                   -- Client user agent
+    success BOOLEAN NOT NULL,                          -- Whether the attempt logged the user in
+    reason VARCHAR(50) NOT NULL,                       -- Outcome, e.g. success, invalid_credentials, locked
+    created_at TIMESTAMP DEFAULT NOW()                 -- Timestamp of the attempt
+);
+
+CREATE INDEX idx_login_attempts_username ON login_attempts (username, created_at);
+CREATE INDEX idx_login_attempts_ip_address ON login_attempts (ip_address, created_at);
+
+-- Failed login counters shared between instances when LOGIN_THROTTLE_STORE=postgres
+CREATE TABLE login_throttle (
+    key VARCHAR(300) PRIMARY KEY,                      -- "user:<username>" or "ip:<address>"
+    failures INT NOT NULL DEFAULT 0,                   -- Failures in the current window
+    last_failure_at TIMESTAMP,                         -- Timestamp of the last failure
+    locked_until TIMESTAMP                             -- Logins are refused until this time
+);
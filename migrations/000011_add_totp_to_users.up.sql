@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);         -- Base32 TOTP secret, set when enrollment starts
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;       -- Set once enrollment is confirmed with a valid code
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;           -- Time step of the last accepted code, blocks replays
+
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Account the code belongs to
+    code_hash VARCHAR(64) NOT NULL,                    -- SHA-256 of the recovery code
+    used_at TIMESTAMP,                                 -- Set once the code has been used
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp when the code was issued
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
@@ -0,0 +1,19 @@
+CREATE TABLE sessions (
+    id VARCHAR(64) PRIMARY KEY,                        -- Same as the family_id of the session's refresh tokens
+    user_id INT NOT NULL,                              -- Logged in user
+    user_agent TEXT,                                   -- Client user agent at login
+    ip_address VARCHAR(64),                            -- Client IP at login
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp of the login
+    last_used_at TIMESTAMP DEFAULT NOW(),              -- Last request or refresh made with the session
+    revoked_at TIMESTAMP,                              -- Set when the session is logged out or revoked
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- Logins made before sessions were tracked
+INSERT INTO sessions (id, user_id, created_at, last_used_at, revoked_at)
+SELECT family_id, user_id, MIN(created_at), MAX(created_at),
+       CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN NOW() END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_order_status_check;
+ALTER TABLE orders ALTER COLUMN order_status DROP NOT NULL;
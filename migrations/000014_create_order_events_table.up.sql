@@ -0,0 +1,21 @@
+CREATE TABLE order_events (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,                             -- Order whose status changed
+    from_status TEXT,                                  -- Previous status, NULL when the order was created
+    to_status TEXT NOT NULL,                           -- New status
+    actor_id INT,                                      -- User who made the change, NULL for the system
+    reason TEXT,                                       -- Optional explanation of the change
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp of the change
+    FOREIGN KEY (order_id) REFERENCES orders (id),
+    FOREIGN KEY (actor_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_order_events_order_id ON order_events (order_id, created_at);
+
+-- Orders created before history was recorded
+INSERT INTO order_events (order_id, to_status, actor_id, reason, created_at)
+SELECT id, 'Pending', userId, 'Order created', created_at FROM orders;
+
+INSERT INTO order_events (order_id, from_status, to_status, reason, created_at)
+SELECT id, 'Pending', order_status, 'Recorded before status history was kept', updated_at
+FROM orders WHERE order_status <> 'Pending';
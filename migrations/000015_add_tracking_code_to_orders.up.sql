@@ -0,0 +1,11 @@
+ALTER TABLE orders ADD COLUMN tracking_code VARCHAR(32); -- Random code recipients track the parcel with
+
+-- Orders created before tracking codes existed get codes like utils.GenerateTrackingCode:
+-- 16 upper-case hex digits from 8 random bytes. The first 8 hex digits of a version 4 UUID
+-- are all random (the version and variant bits come later), so two UUIDs give the 64 bits.
+-- gen_random_uuid is built in from PostgreSQL 13.
+UPDATE orders SET tracking_code = UPPER(LEFT(gen_random_uuid()::text, 8) || LEFT(gen_random_uuid()::text, 8))
+WHERE tracking_code IS NULL;
+
+ALTER TABLE orders ALTER COLUMN tracking_code SET NOT NULL;
+CREATE UNIQUE INDEX idx_orders_tracking_code ON orders (tracking_code);
@@ -0,0 +1,17 @@
+CREATE TABLE bulk_order_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,                              -- Merchant who uploaded the file
+    file_name TEXT,                                    -- Name of the uploaded file
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',      -- queued, processing, completed or failed
+    total_rows INT NOT NULL DEFAULT 0,                 -- Data rows in the file
+    processed_rows INT NOT NULL DEFAULT 0,             -- Rows processed so far
+    succeeded_rows INT NOT NULL DEFAULT 0,             -- Rows that created an order
+    failed_rows INT NOT NULL DEFAULT 0,                -- Rows rejected by validation or on insert
+    results JSONB,                                     -- Per-row results once the job has finished
+    error TEXT,                                        -- Reason the whole job failed
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp of the upload
+    finished_at TIMESTAMP,                             -- Timestamp the job completed or failed
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_bulk_order_jobs_user_id ON bulk_order_jobs (user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE idempotency_keys (
+    user_id INT NOT NULL,                              -- Owner of the key, keys are scoped per user
+    key VARCHAR(255) NOT NULL,                         -- Value of the Idempotency-Key header
+    request_hash VARCHAR(64) NOT NULL,                 -- SHA-256 of the method, path and body of the first request
+    status_code INT,                                   -- Status of the stored response, NULL while the request is in flight
+    content_type TEXT,                                 -- Content type of the stored response
+    response_body BYTEA,                               -- Body of the stored response
+    created_at TIMESTAMP DEFAULT NOW(),                -- Timestamp of the first request
+    expires_at TIMESTAMP NOT NULL,                     -- The key can be reused after this time
+    PRIMARY KEY (user_id, key),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
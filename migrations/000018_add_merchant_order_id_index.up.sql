@@ -0,0 +1,28 @@
+-- References reused before uniqueness was enforced are reported rather than renamed,
+-- since merchants reconcile their systems against them; they have to be resolved with
+-- the merchants before the migration is run again.
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(format('user %s store %s reference %L (orders %s)', userId, store_id, merchant_order_id, ids), '; ')
+    INTO conflicts
+    FROM (
+        SELECT userId, store_id, merchant_order_id, string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM orders
+        WHERE merchant_order_id <> ''
+        GROUP BY userId, store_id, merchant_order_id
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate merchant order IDs: %', conflicts;
+    END IF;
+END $$;
+
+-- store_id is not a reference to a stores table but a number each merchant picks, so
+-- two merchants can both have a store 1. Keying the index on (store_id, merchant_order_id)
+-- alone would let one merchant's references block, and reveal, another's; the user
+-- column keeps uniqueness within each merchant's own stores.
+CREATE UNIQUE INDEX idx_orders_merchant_order_id ON orders (userId, store_id, merchant_order_id)
+WHERE merchant_order_id <> '';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_orders_userid_created_at_id;
+ALTER TABLE orders ALTER COLUMN created_at DROP NOT NULL;
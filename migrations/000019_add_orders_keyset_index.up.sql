@@ -0,0 +1,6 @@
+-- Keyset pagination compares (created_at, id), which a missing timestamp would break
+UPDATE orders SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+ALTER TABLE orders ALTER COLUMN created_at SET NOT NULL;
+
+-- Serves a merchant's order listing by creation time, in either direction
+CREATE INDEX idx_orders_userid_created_at_id ON orders (userId, created_at, id);
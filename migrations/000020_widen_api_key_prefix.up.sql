@@ -0,0 +1,2 @@
+-- Prefixes carry 8 random bytes, so that collisions under the unique index are not a concern
+ALTER TABLE api_keys ALTER COLUMN prefix TYPE VARCHAR(32);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bulk_order_jobs_status_updated_at;
+ALTER TABLE bulk_order_jobs DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,5 @@
+-- Running jobs touch the row with every batch, so jobs lost in a restart can be told apart
+ALTER TABLE bulk_order_jobs ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+UPDATE bulk_order_jobs SET updated_at = COALESCE(finished_at, created_at, NOW());
+
+CREATE INDEX idx_bulk_order_jobs_status_updated_at ON bulk_order_jobs (status, updated_at);
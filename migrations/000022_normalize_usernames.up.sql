@@ -0,0 +1,24 @@
+-- Usernames are matched the way registration stores them: trimmed and lowercased.
+-- Accounts created before registration existed may differ only in case or spacing;
+-- those have to be merged by hand, so the migration stops and lists them.
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(normalized || ' (ids ' || ids || ')', ', ') INTO conflicts
+    FROM (
+        SELECT LOWER(TRIM(username)) AS normalized, string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM users
+        GROUP BY LOWER(TRIM(username))
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'usernames differing only in case or spacing: %', conflicts;
+    END IF;
+END $$;
+
+UPDATE users SET username = LOWER(TRIM(username)) WHERE username <> LOWER(TRIM(username));
+
+-- Keeps "Alice@x.com" from being registered next to "alice@x.com" and serves the lookup
+CREATE UNIQUE INDEX idx_users_username_lower ON users (LOWER(username));
@@ -0,0 +1,3 @@
+-- Identifies the request holding a key, so a request whose claim was taken over after
+-- the in-flight lease cannot store or release the record of the request that took it
+ALTER TABLE idempotency_keys ADD COLUMN claim_token VARCHAR(64);
@@ -0,0 +1,8 @@
+-- Request rate limit windows shared between instances when LOGIN_THROTTLE_STORE=postgres
+CREATE TABLE rate_limits (
+    key VARCHAR(300) PRIMARY KEY,                      -- What is limited, e.g. the client IP
+    window_start TIMESTAMP NOT NULL,                   -- Start of the current window
+    count INT NOT NULL                                 -- Requests counted in the current window
+);
+
+CREATE INDEX idx_rate_limits_window_start ON rate_limits (window_start);
@@ -26,4 +26,5 @@ type Order struct {
 	Discount           float64 `json:"discount"`
 	DeliveryFee        float64 `json:"delivery_fee"`
 	Archive            bool    `json:"archive"`
+	TrackingCode       string  `json:"tracking_code"`
 }
@@ -0,0 +1,50 @@
+package model
+
+// Order statuses, in lifecycle order
+const (
+	StatusPending         = "Pending"
+	StatusPickupRequested = "PickupRequested"
+	StatusPickedUp        = "PickedUp"
+	StatusAtHub           = "AtHub"
+	StatusInTransit       = "InTransit"
+	StatusOutForDelivery  = "OutForDelivery"
+	StatusDelivered       = "Delivered"
+	StatusReturned        = "Returned"
+	StatusCancelled       = "Cancelled"
+)
+
+// orderTransitions lists the statuses each status may move to. Delivered, Returned
+// and Cancelled are final.
+var orderTransitions = map[string][]string{
+	StatusPending:         {StatusPickupRequested, StatusCancelled},
+	StatusPickupRequested: {StatusPickedUp, StatusPending, StatusCancelled},
+	StatusPickedUp:        {StatusAtHub},
+	StatusAtHub:           {StatusInTransit, StatusOutForDelivery, StatusReturned},
+	StatusInTransit:       {StatusAtHub, StatusOutForDelivery},
+	StatusOutForDelivery:  {StatusDelivered, StatusAtHub, StatusReturned},
+	StatusDelivered:       {},
+	StatusReturned:        {},
+	StatusCancelled:       {},
+}
+
+// ValidOrderStatus reports whether status is a known order status
+func ValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanCancel reports whether an order in the given status may still be cancelled,
+// which is only the case before it is picked up
+func CanCancel(status string) bool {
+	return CanTransition(status, StatusCancelled)
+}
@@ -0,0 +1,74 @@
+package model
+
+import "testing"
+
+var allStatuses = []string{
+	StatusPending, StatusPickupRequested, StatusPickedUp, StatusAtHub, StatusInTransit,
+	StatusOutForDelivery, StatusDelivered, StatusReturned, StatusCancelled,
+}
+
+func TestCanTransition(t *testing.T) {
+	// Every allowed edge of the lifecycle; any pair not listed must be refused
+	allowed := map[string][]string{
+		StatusPending:         {StatusPickupRequested, StatusCancelled},
+		StatusPickupRequested: {StatusPickedUp, StatusPending, StatusCancelled},
+		StatusPickedUp:        {StatusAtHub},
+		StatusAtHub:           {StatusInTransit, StatusOutForDelivery, StatusReturned},
+		StatusInTransit:       {StatusAtHub, StatusOutForDelivery},
+		StatusOutForDelivery:  {StatusDelivered, StatusAtHub, StatusReturned},
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				if next == to {
+					want = true
+				}
+			}
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTerminalStatusesRejectEverything(t *testing.T) {
+	for _, from := range []string{StatusDelivered, StatusReturned, StatusCancelled} {
+		for _, to := range allStatuses {
+			if CanTransition(from, to) {
+				t.Errorf("terminal status %s may move to %s", from, to)
+			}
+		}
+		if CanCancel(from) {
+			t.Errorf("terminal status %s may be cancelled", from)
+		}
+	}
+}
+
+func TestUnknownStatuses(t *testing.T) {
+	for _, status := range []string{"", "Cancel", "pending", "Lost"} {
+		if ValidOrderStatus(status) {
+			t.Errorf("ValidOrderStatus(%q) = true", status)
+		}
+		for _, other := range allStatuses {
+			if CanTransition(status, other) || CanTransition(other, status) {
+				t.Errorf("unknown status %q takes part in a transition with %s", status, other)
+			}
+		}
+	}
+	for _, status := range allStatuses {
+		if !ValidOrderStatus(status) {
+			t.Errorf("ValidOrderStatus(%q) = false", status)
+		}
+	}
+}
+
+func TestCanCancel(t *testing.T) {
+	for _, status := range allStatuses {
+		want := status == StatusPending || status == StatusPickupRequested
+		if got := CanCancel(status); got != want {
+			t.Errorf("CanCancel(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
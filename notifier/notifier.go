@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message represents a notification sent to a user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages such as verification codes to users
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier selected by kind ("log" or "file"), defaulting to LogNotifier
+func New(kind, path string) (Notifier, error) {
+	switch kind {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		if path == "" {
+			return nil, fmt.Errorf("file notifier requires a path")
+		}
+		return &FileNotifier{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", kind)
+	}
+}
+
+// LogNotifier writes messages to the application log, for local development
+type LogNotifier struct{}
+
+// Notify logs the message
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log.Printf("Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a file, for local development
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Notify appends the message to the file
+func (n *FileNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening notification file: %v", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "[%s] To: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("error writing notification: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Scopes that can be granted to an API key
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+)
+
+// APIKeyScopes lists every scope an API key can be granted
+var APIKeyScopes = []string{ScopeOrdersRead, ScopeOrdersWrite}
+
+// ErrAPIKeyNotFound is returned when an API key does not exist or belongs to another user
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey represents a merchant API key
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	SecretHash string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeyRepository defines methods for interacting with the api_keys data.
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+// NewAPIKeyRepository initializes and returns a new APIKeyRepository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+// apiKeyColumns lists the columns scanned by scanAPIKey, in order
+const apiKeyColumns = `id, user_id, name, prefix, secret_hash, scopes, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.SecretHash,
+		pq.Array(&key.Scopes), &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Create stores a new API key for the user.
+func (r *APIKeyRepository) Create(userID int, name, prefix, secretHash string, scopes []string) (*APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes)
+    VALUES ($1, $2, $3, $4, $5) RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(r.DB.QueryRow(query, userID, name, prefix, secretHash, pq.Array(scopes)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating api key: %v", err)
+	}
+	return key, nil
+}
+
+// ListByUser returns every API key of the user, newest first.
+func (r *APIKeyRepository) ListByUser(userID int) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching api keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning api key: %v", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Update renames the user's API key and replaces its scopes.
+func (r *APIKeyRepository) Update(userID, id int, name string, scopes []string) (*APIKey, error) {
+	query := `UPDATE api_keys SET name = $3, scopes = $4
+    WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(r.DB.QueryRow(query, id, userID, name, pq.Array(scopes)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("error updating api key: %v", err)
+	}
+	return key, nil
+}
+
+// GetByUser fetches one of the user's API keys.
+func (r *APIKeyRepository) GetByUser(userID, id int) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1 AND user_id = $2`
+	key, err := scanAPIKey(r.DB.QueryRow(query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("error fetching api key: %v", err)
+	}
+	return key, nil
+}
+
+// Revoke permanently disables one of the user's API keys.
+func (r *APIKeyRepository) Revoke(userID, id int) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := r.DB.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking api key: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to fetch affected rows: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// revokeUserAPIKeys disables every active API key of the user
+func revokeUserAPIKeys(db execer, userID int) error {
+	if _, err := db.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return fmt.Errorf("error revoking user api keys: %v", err)
+	}
+	return nil
+}
+
+// GetActiveByPrefix fetches a key that has not been revoked by its public prefix,
+// returning nil if there is none.
+func (r *APIKeyRepository) GetActiveByPrefix(prefix string) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1 AND revoked_at IS NULL`
+	key, err := scanAPIKey(r.DB.QueryRow(query, prefix))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching api key: %v", err)
+	}
+	return key, nil
+}
+
+// Touch records that the key has just been used.
+func (r *APIKeyRepository) Touch(id int) error {
+	if _, err := r.DB.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("error updating api key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testAPIKey creates an active API key for the user; see testUser
+func testAPIKey(t *testing.T, userRepo *UserRepository, userID int) (*APIKeyRepository, string) {
+	t.Helper()
+	apiKeyRepo := NewAPIKeyRepository(userRepo.DB)
+	prefix := fmt.Sprintf("ok_%x", time.Now().UnixNano())
+	if _, err := apiKeyRepo.Create(userID, "test", prefix, "hash", []string{ScopeOrdersRead}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() { userRepo.DB.Exec(`DELETE FROM api_keys WHERE user_id = $1`, userID) })
+	return apiKeyRepo, prefix
+}
+
+func TestRevokeAllAccessRevokesAPIKeys(t *testing.T) {
+	userRepo, userID := testUser(t)
+	apiKeyRepo, prefix := testAPIKey(t, userRepo, userID)
+
+	if err := userRepo.RevokeAllAccess(userID); err != nil {
+		t.Fatalf("RevokeAllAccess: %v", err)
+	}
+	if key, err := apiKeyRepo.GetActiveByPrefix(prefix); err != nil || key != nil {
+		t.Errorf("GetActiveByPrefix after RevokeAllAccess = (%v, %v), want (nil, nil)", key, err)
+	}
+}
+
+func TestResetPasswordRevokesAPIKeys(t *testing.T) {
+	userRepo, userID := testUser(t)
+	apiKeyRepo, prefix := testAPIKey(t, userRepo, userID)
+	t.Cleanup(func() { userRepo.DB.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1`, userID) })
+
+	tokenHash := fmt.Sprintf("reset-%d", time.Now().UnixNano())
+	if err := userRepo.CreatePasswordResetToken(userID, tokenHash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if _, err := userRepo.ResetPassword(tokenHash, "new-hash"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if key, err := apiKeyRepo.GetActiveByPrefix(prefix); err != nil || key != nil {
+		t.Errorf("GetActiveByPrefix after ResetPassword = (%v, %v), want (nil, nil)", key, err)
+	}
+}
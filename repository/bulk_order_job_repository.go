@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Bulk order job statuses
+const (
+	BulkJobQueued     = "queued"
+	BulkJobProcessing = "processing"
+	BulkJobCompleted  = "completed"
+	BulkJobFailed     = "failed"
+)
+
+// bulkJobInterruptedReason is the error of jobs that stopped making progress, most
+// likely because the server restarted while they ran. It is a format() template that
+// is given the processed, total and succeeded row counts.
+const bulkJobInterruptedReason = "The upload was interrupted after %s of %s rows. %s orders were created " +
+	"and are listed in the results; upload only the other rows again."
+
+// ErrBulkJobNotFound is returned when a job does not exist or belongs to another user
+var ErrBulkJobNotFound = errors.New("bulk order job not found")
+
+// ErrBulkJobNotRunning is returned when updating a job that already completed or failed
+var ErrBulkJobNotRunning = errors.New("bulk order job is not running")
+
+// BulkRowResult is the outcome of one row of a bulk upload: the created order or the
+// validation errors in the same shape as a 422 response
+type BulkRowResult struct {
+	Row           int                 `json:"row"`
+	ConsignmentID int                 `json:"consignment_id,omitempty"`
+	TrackingCode  string              `json:"tracking_code,omitempty"`
+	Errors        map[string][]string `json:"errors,omitempty"`
+}
+
+// BulkOrderJob represents an asynchronously processed bulk upload
+type BulkOrderJob struct {
+	ID            int             `json:"id"`
+	UserID        int             `json:"-"`
+	FileName      string          `json:"file_name"`
+	Status        string          `json:"status"`
+	TotalRows     int             `json:"total_rows"`
+	ProcessedRows int             `json:"processed_rows"`
+	SucceededRows int             `json:"succeeded_rows"`
+	FailedRows    int             `json:"failed_rows"`
+	Results       []BulkRowResult `json:"results,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
+}
+
+// BulkOrderJobRepository defines methods for interacting with the bulk_order_jobs data.
+type BulkOrderJobRepository struct {
+	DB *sql.DB
+}
+
+// NewBulkOrderJobRepository initializes and returns a new BulkOrderJobRepository
+func NewBulkOrderJobRepository(db *sql.DB) *BulkOrderJobRepository {
+	return &BulkOrderJobRepository{DB: db}
+}
+
+// Create queues a job for the user's upload and returns its ID.
+func (r *BulkOrderJobRepository) Create(userID int, fileName string, totalRows int) (int, error) {
+	var id int
+	query := `INSERT INTO bulk_order_jobs (user_id, file_name, status, total_rows) VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := r.DB.QueryRow(query, userID, fileName, BulkJobQueued, totalRows).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error creating bulk order job: %v", err)
+	}
+	return id, nil
+}
+
+// Get fetches one of the user's jobs.
+func (r *BulkOrderJobRepository) Get(userID, id int) (*BulkOrderJob, error) {
+	var (
+		job        BulkOrderJob
+		results    []byte
+		finishedAt sql.NullTime
+	)
+	query := `SELECT id, user_id, COALESCE(file_name, ''), status, total_rows, processed_rows, succeeded_rows,
+    failed_rows, results, COALESCE(error, ''), created_at, finished_at
+    FROM bulk_order_jobs WHERE id = $1 AND user_id = $2`
+	err := r.DB.QueryRow(query, id, userID).Scan(&job.ID, &job.UserID, &job.FileName, &job.Status,
+		&job.TotalRows, &job.ProcessedRows, &job.SucceededRows, &job.FailedRows, &results, &job.Error,
+		&job.CreatedAt, &finishedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBulkJobNotFound
+		}
+		return nil, fmt.Errorf("error fetching bulk order job: %v", err)
+	}
+
+	if results != nil {
+		if err := json.Unmarshal(results, &job.Results); err != nil {
+			return nil, fmt.Errorf("error decoding bulk order job results: %v", err)
+		}
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// UpdateProgress marks the job as processing and stores the results of the rows done
+// so far, so the orders already created stay visible if the job is interrupted.
+// It returns ErrBulkJobNotRunning if the job was failed in the meantime.
+func (r *BulkOrderJobRepository) UpdateProgress(id int, results []BulkRowResult) error {
+	encoded, succeeded, failed, err := encodeBulkResults(results)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE bulk_order_jobs SET status = $2, processed_rows = $3, succeeded_rows = $4, failed_rows = $5,
+    results = $6, updated_at = NOW() WHERE id = $1 AND status IN ($7, $8)`
+	result, err := r.DB.Exec(query, id, BulkJobProcessing, len(results), succeeded, failed, encoded,
+		BulkJobQueued, BulkJobProcessing)
+	if err != nil {
+		return fmt.Errorf("error updating bulk order job: %v", err)
+	}
+	return bulkJobUpdated(result)
+}
+
+// Complete stores the per-row results of a finished job. It returns ErrBulkJobNotRunning
+// if the job was failed in the meantime.
+func (r *BulkOrderJobRepository) Complete(id int, results []BulkRowResult) error {
+	encoded, succeeded, failed, err := encodeBulkResults(results)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE bulk_order_jobs SET status = $2, processed_rows = $3, succeeded_rows = $4, failed_rows = $5,
+    results = $6, finished_at = NOW(), updated_at = NOW() WHERE id = $1 AND status IN ($7, $8)`
+	result, err := r.DB.Exec(query, id, BulkJobCompleted, len(results), succeeded, failed, encoded,
+		BulkJobQueued, BulkJobProcessing)
+	if err != nil {
+		return fmt.Errorf("error completing bulk order job: %v", err)
+	}
+	return bulkJobUpdated(result)
+}
+
+// Fail records why a job could not be processed. It returns ErrBulkJobNotRunning if
+// the job already finished.
+func (r *BulkOrderJobRepository) Fail(id int, reason string) error {
+	query := `UPDATE bulk_order_jobs SET status = $2, error = $3, finished_at = NOW(), updated_at = NOW()
+    WHERE id = $1 AND status IN ($4, $5)`
+	result, err := r.DB.Exec(query, id, BulkJobFailed, reason, BulkJobQueued, BulkJobProcessing)
+	if err != nil {
+		return fmt.Errorf("error failing bulk order job: %v", err)
+	}
+	return bulkJobUpdated(result)
+}
+
+// FailStale fails the queued and processing jobs that made no progress for longer than
+// idle. Jobs run in the process that accepted the upload, so a job lost in a restart
+// would otherwise never finish. The results stored with the last progress are kept, and
+// the error tells the merchant how many orders were created. It returns the number of
+// jobs failed.
+func (r *BulkOrderJobRepository) FailStale(idle time.Duration) (int64, error) {
+	query := `UPDATE bulk_order_jobs SET status = $1, error = format($2, processed_rows, total_rows, succeeded_rows),
+    finished_at = NOW(), updated_at = NOW()
+    WHERE status IN ($3, $4) AND updated_at < NOW() - $5 * INTERVAL '1 second'`
+	result, err := r.DB.Exec(query, BulkJobFailed, bulkJobInterruptedReason, BulkJobQueued, BulkJobProcessing, idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("error failing stale bulk order jobs: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// StartFailingStale runs FailStale right away and then in the background at the given interval.
+func (r *BulkOrderJobRepository) StartFailingStale(interval, idle time.Duration) {
+	failStale := func() {
+		failed, err := r.FailStale(idle)
+		if err != nil {
+			log.Printf("Failed to fail stale bulk order jobs: %v", err)
+			return
+		}
+		if failed > 0 {
+			log.Printf("Failed %d interrupted bulk order jobs", failed)
+		}
+	}
+
+	failStale()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			failStale()
+		}
+	}()
+}
+
+// encodeBulkResults encodes the per-row results of a job and counts its outcomes
+func encodeBulkResults(results []BulkRowResult) (encoded []byte, succeeded, failed int, err error) {
+	encoded, err = json.Marshal(results)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("error encoding bulk order job results: %v", err)
+	}
+	for _, result := range results {
+		if result.ConsignmentID != 0 {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	return encoded, succeeded, failed, nil
+}
+
+// bulkJobUpdated turns an update that matched no running job into ErrBulkJobNotRunning
+func bulkJobUpdated(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to fetch affected rows: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBulkJobNotRunning
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+// testBulkJob creates a job for a test user; see testUser
+func testBulkJob(t *testing.T, totalRows int) (*BulkOrderJobRepository, int, int) {
+	t.Helper()
+	userRepo, userID := testUser(t)
+	jobRepo := NewBulkOrderJobRepository(userRepo.DB)
+	jobID, err := jobRepo.Create(userID, "orders.csv", totalRows)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() { userRepo.DB.Exec(`DELETE FROM bulk_order_jobs WHERE id = $1`, jobID) })
+	return jobRepo, userID, jobID
+}
+
+func TestBulkJobStaleKeepsProgress(t *testing.T) {
+	jobRepo, userID, jobID := testBulkJob(t, 3)
+
+	done := []BulkRowResult{
+		{Row: 2, ConsignmentID: 10},
+		{Row: 3, Errors: map[string][]string{"recipient_name": {"required"}}},
+	}
+	if err := jobRepo.UpdateProgress(jobID, done); err != nil {
+		t.Fatalf("UpdateProgress: %v", err)
+	}
+	if _, err := jobRepo.FailStale(0); err != nil {
+		t.Fatalf("FailStale: %v", err)
+	}
+
+	job, err := jobRepo.Get(userID, jobID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if job.Status != BulkJobFailed || job.SucceededRows != 1 || job.FailedRows != 1 || len(job.Results) != 2 {
+		t.Errorf("job = %+v, want failed with the stored results of 2 rows", job)
+	}
+	want := "The upload was interrupted after 2 of 3 rows. 1 orders were created and are listed in the results; upload only the other rows again."
+	if job.Error != want {
+		t.Errorf("error = %q, want %q", job.Error, want)
+	}
+}
+
+func TestBulkJobFinishedOnce(t *testing.T) {
+	jobRepo, userID, jobID := testBulkJob(t, 1)
+
+	if err := jobRepo.Fail(jobID, "interrupted"); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	// A slow job finishing after it was failed must not overwrite the failure
+	if err := jobRepo.Complete(jobID, []BulkRowResult{{Row: 2, ConsignmentID: 10}}); !errors.Is(err, ErrBulkJobNotRunning) {
+		t.Errorf("Complete after Fail error = %v, want ErrBulkJobNotRunning", err)
+	}
+	if err := jobRepo.UpdateProgress(jobID, nil); !errors.Is(err, ErrBulkJobNotRunning) {
+		t.Errorf("UpdateProgress after Fail error = %v, want ErrBulkJobNotRunning", err)
+	}
+	if err := jobRepo.Fail(jobID, "again"); !errors.Is(err, ErrBulkJobNotRunning) {
+		t.Errorf("second Fail error = %v, want ErrBulkJobNotRunning", err)
+	}
+
+	job, err := jobRepo.Get(userID, jobID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if job.Status != BulkJobFailed || job.Error != "interrupted" {
+		t.Errorf("job = %+v, want the first failure", job)
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// IdempotencyKey represents a stored Idempotency-Key and, once the request completed, its response
+type IdempotencyKey struct {
+	UserID       int
+	Key          string
+	RequestHash  string
+	StatusCode   int // Zero while the first request is still in flight
+	ContentType  string
+	ResponseBody []byte
+}
+
+// IdempotencyKeyRepository defines methods for interacting with the idempotency_keys data.
+type IdempotencyKeyRepository struct {
+	DB *sql.DB
+}
+
+// NewIdempotencyKeyRepository initializes and returns a new IdempotencyKeyRepository
+func NewIdempotencyKeyRepository(db *sql.DB) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{DB: db}
+}
+
+// Claim reserves the key for a request, identified by claimToken. It returns true if
+// the caller now owns the key, either because it was unused, because its previous use
+// expired, or because a request with the same hash has held it in flight for longer
+// than lease and is taken to have died with its process. A different request never
+// takes over a key. The primary key makes concurrent claims of the same key safe:
+// only one of them can win.
+func (r *IdempotencyKeyRepository) Claim(userID int, key, requestHash, claimToken string, expiresAt time.Time, lease time.Duration) (bool, error) {
+	query := `INSERT INTO idempotency_keys (user_id, key, request_hash, claim_token, expires_at) VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (user_id, key) DO UPDATE
+    SET request_hash = EXCLUDED.request_hash, claim_token = EXCLUDED.claim_token, status_code = NULL,
+        content_type = NULL, response_body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+    WHERE idempotency_keys.expires_at < $6
+        OR (idempotency_keys.status_code IS NULL AND idempotency_keys.request_hash = EXCLUDED.request_hash
+            AND idempotency_keys.created_at < NOW() - $7 * INTERVAL '1 second')
+    RETURNING user_id`
+	var claimedBy int
+	err := r.DB.QueryRow(query, userID, key, requestHash, claimToken, expiresAt.UTC(), time.Now().UTC(), lease.Seconds()).Scan(&claimedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming idempotency key: %v", err)
+	}
+	return true, nil
+}
+
+// Get fetches a key of the user, or nil if it does not exist.
+func (r *IdempotencyKeyRepository) Get(userID int, key string) (*IdempotencyKey, error) {
+	var (
+		stored      IdempotencyKey
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+	)
+	query := `SELECT user_id, key, request_hash, status_code, content_type, response_body
+    FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	err := r.DB.QueryRow(query, userID, key).Scan(&stored.UserID, &stored.Key, &stored.RequestHash,
+		&statusCode, &contentType, &stored.ResponseBody)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching idempotency key: %v", err)
+	}
+	stored.StatusCode = int(statusCode.Int64)
+	stored.ContentType = contentType.String
+	return &stored, nil
+}
+
+// Complete stores the response of the request that claimed the key with claimToken so
+// it can be replayed. Nothing is stored if the claim was taken over in the meantime.
+func (r *IdempotencyKeyRepository) Complete(userID int, key, claimToken string, statusCode int, contentType string, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $4, content_type = $5, response_body = $6
+    WHERE user_id = $1 AND key = $2 AND claim_token = $3`
+	if _, err := r.DB.Exec(query, userID, key, claimToken, statusCode, contentType, body); err != nil {
+		return fmt.Errorf("error storing idempotent response: %v", err)
+	}
+	return nil
+}
+
+// Release forgets a key claimed with claimToken whose request failed, so the client can
+// retry with it. A claim taken over in the meantime is left alone.
+func (r *IdempotencyKeyRepository) Release(userID int, key, claimToken string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND claim_token = $3`
+	if _, err := r.DB.Exec(query, userID, key, claimToken); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %v", err)
+	}
+	return nil
+}
+
+// Prune deletes expired keys.
+func (r *IdempotencyKeyRepository) Prune() error {
+	if _, err := r.DB.Exec(`DELETE FROM idempotency_keys WHERE expires_at < $1`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("error pruning idempotency keys: %v", err)
+	}
+	return nil
+}
+
+// StartPruning runs Prune in the background at the given interval.
+func (r *IdempotencyKeyRepository) StartPruning(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Prune(); err != nil {
+				log.Printf("Failed to prune idempotency keys: %v", err)
+			}
+		}
+	}()
+}
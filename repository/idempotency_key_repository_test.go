@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdempotencyClaimTakeover(t *testing.T) {
+	userRepo, userID := testUser(t)
+	repo := NewIdempotencyKeyRepository(userRepo.DB)
+	t.Cleanup(func() { userRepo.DB.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1`, userID) })
+	expiresAt := time.Now().Add(time.Hour)
+
+	if ok, err := repo.Claim(userID, "key", "hash-a", "first", expiresAt, time.Hour); err != nil || !ok {
+		t.Fatalf("first claim = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, err := repo.Claim(userID, "key", "hash-a", "early", expiresAt, time.Hour); err != nil || ok {
+		t.Errorf("claim within the lease = (%v, %v), want (false, nil)", ok, err)
+	}
+	// With a lease of zero the first claim counts as lost, but only the same request may take it over
+	if ok, err := repo.Claim(userID, "key", "hash-b", "other", expiresAt, 0); err != nil || ok {
+		t.Errorf("claim by a different request = (%v, %v), want (false, nil)", ok, err)
+	}
+	if ok, err := repo.Claim(userID, "key", "hash-a", "second", expiresAt, 0); err != nil || !ok {
+		t.Fatalf("takeover = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// The first request finishing late must not touch the record of the second
+	if err := repo.Complete(userID, "key", "first", 201, "application/json", []byte(`{"first":true}`)); err != nil {
+		t.Fatalf("Complete(first): %v", err)
+	}
+	if err := repo.Release(userID, "key", "first"); err != nil {
+		t.Fatalf("Release(first): %v", err)
+	}
+	stored, err := repo.Get(userID, "key")
+	if err != nil || stored == nil {
+		t.Fatalf("Get = (%v, %v), want the second claim", stored, err)
+	}
+	if stored.StatusCode != 0 {
+		t.Errorf("status code = %d, want 0 while the second request is in flight", stored.StatusCode)
+	}
+
+	if err := repo.Complete(userID, "key", "second", 201, "application/json", []byte(`{"second":true}`)); err != nil {
+		t.Fatalf("Complete(second): %v", err)
+	}
+	stored, err = repo.Get(userID, "key")
+	if err != nil || stored == nil || stored.StatusCode != 201 || string(stored.ResponseBody) != `{"second":true}` {
+		t.Errorf("Get after Complete(second) = (%+v, %v), want its response", stored, err)
+	}
+}
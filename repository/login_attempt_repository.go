@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-orders-app/throttle"
+)
+
+// LoginAttempt represents one audited login attempt
+type LoginAttempt struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginAttemptRepository defines methods for interacting with the login_attempts audit data.
+type LoginAttemptRepository struct {
+	DB *sql.DB
+}
+
+// NewLoginAttemptRepository initializes and returns a new LoginAttemptRepository
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{DB: db}
+}
+
+// Record stores a login attempt.
+func (r *LoginAttemptRepository) Record(attempt LoginAttempt) error {
+	query := `INSERT INTO login_attempts (username, ip_address, user_agent, success, reason) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := r.DB.Exec(query, attempt.Username, attempt.IPAddress, attempt.UserAgent, attempt.Success, attempt.Reason); err != nil {
+		return fmt.Errorf("error recording login attempt: %v", err)
+	}
+	return nil
+}
+
+// List fetches login attempts, newest first, optionally filtered by username and IP.
+func (r *LoginAttemptRepository) List(username, ipAddress string, limit, page int) ([]LoginAttempt, int, error) {
+	offset := (page - 1) * limit
+	where := `WHERE ($1 = '' OR username = $1) AND ($2 = '' OR ip_address = $2)`
+
+	query := `SELECT id, username, ip_address, COALESCE(user_agent, ''), success, reason, created_at
+    FROM login_attempts ` + where + ` ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`
+	rows, err := r.DB.Query(query, username, ipAddress, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching login attempts: %v", err)
+	}
+	defer rows.Close()
+
+	attempts := []LoginAttempt{}
+	for rows.Next() {
+		var attempt LoginAttempt
+		if err := rows.Scan(&attempt.ID, &attempt.Username, &attempt.IPAddress, &attempt.UserAgent,
+			&attempt.Success, &attempt.Reason, &attempt.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("error scanning login attempt: %v", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM login_attempts ` + where
+	if err := r.DB.QueryRow(countQuery, username, ipAddress).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting login attempts: %v", err)
+	}
+
+	return attempts, total, nil
+}
+
+// LoginThrottleRepository is a throttle.Store backed by Postgres, so failed login
+// counters are shared by every instance of the service.
+type LoginThrottleRepository struct {
+	DB *sql.DB
+}
+
+// NewLoginThrottleRepository initializes and returns a new LoginThrottleRepository
+func NewLoginThrottleRepository(db *sql.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{DB: db}
+}
+
+// Get returns the throttle entry for key.
+func (r *LoginThrottleRepository) Get(key string) (throttle.Entry, error) {
+	return getThrottleEntry(r.DB.QueryRow(`SELECT failures, last_failure_at, locked_until FROM login_throttle WHERE key = $1`, key))
+}
+
+// RecordFailure replaces the entry for key with update(current), holding a row lock meanwhile.
+func (r *LoginThrottleRepository) RecordFailure(key string, update func(throttle.Entry) throttle.Entry) (throttle.Entry, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return throttle.Entry{}, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO login_throttle (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`, key); err != nil {
+		return throttle.Entry{}, fmt.Errorf("error creating throttle entry: %v", err)
+	}
+
+	current, err := getThrottleEntry(tx.QueryRow(`SELECT failures, last_failure_at, locked_until FROM login_throttle WHERE key = $1 FOR UPDATE`, key))
+	if err != nil {
+		return throttle.Entry{}, err
+	}
+
+	next := update(current)
+	query := `UPDATE login_throttle SET failures = $2, last_failure_at = $3, locked_until = $4 WHERE key = $1`
+	if _, err := tx.Exec(query, key, next.Failures, nullTime(next.LastFailure), nullTime(next.LockedUntil)); err != nil {
+		return throttle.Entry{}, fmt.Errorf("error updating throttle entry: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return throttle.Entry{}, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return next, nil
+}
+
+// Reset forgets the entry for key.
+func (r *LoginThrottleRepository) Reset(key string) error {
+	if _, err := r.DB.Exec(`DELETE FROM login_throttle WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("error resetting throttle entry: %v", err)
+	}
+	return nil
+}
+
+func getThrottleEntry(row *sql.Row) (throttle.Entry, error) {
+	var (
+		entry       throttle.Entry
+		lastFailure sql.NullTime
+		lockedUntil sql.NullTime
+	)
+	if err := row.Scan(&entry.Failures, &lastFailure, &lockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return throttle.Entry{}, nil
+		}
+		return throttle.Entry{}, fmt.Errorf("error fetching throttle entry: %v", err)
+	}
+	entry.LastFailure = lastFailure.Time
+	entry.LockedUntil = lockedUntil.Time
+	return entry, nil
+}
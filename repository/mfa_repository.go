@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"fmt"
+)
+
+// SetPendingTOTPSecret stores a TOTP secret that takes effect once EnableTOTP confirms it.
+func (r *UserRepository) SetPendingTOTPSecret(userID int, secret string) error {
+	query := `UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled_at IS NULL`
+	if _, err := r.DB.Exec(query, userID, secret); err != nil {
+		return fmt.Errorf("error storing totp secret: %v", err)
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication and replaces the user's recovery codes.
+func (r *UserRepository) EnableTOTP(userID int, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_enabled_at = NOW() WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("error enabling totp: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("error creating recovery code: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// DisableTOTP turns off two-factor authentication and deletes the user's recovery codes.
+func (r *UserRepository) DisableTOTP(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("error disabling totp: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false if a code
+// of that step or a later one was already used, so each code works only once.
+func (r *UserRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`
+	result, err := r.DB.Exec(query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error recording totp step: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch affected rows: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes, returning false if it does not match any.
+func (r *UserRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = NOW()
+    WHERE id = (SELECT id FROM recovery_codes WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1)
+    AND used_at IS NULL`
+	result, err := r.DB.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch affected rows: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
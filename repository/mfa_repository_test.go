@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testUser opens the migrated database named by TEST_DATABASE_URL and creates a user
+// that is deleted when the test ends. Tests using it are skipped without the variable.
+func testUser(t *testing.T) (*UserRepository, int) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewUserRepository(db)
+	username := fmt.Sprintf("mfa-test-%d@example.com", time.Now().UnixNano())
+	userID, err := repo.CreateUnverifiedUser(username, "hash", fmt.Sprintf("token-%s", username), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		db.Exec(`DELETE FROM verification_tokens WHERE user_id = $1`, userID)
+		db.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+	return repo, userID
+}
+
+func TestUseRecoveryCodeOnlyOnce(t *testing.T) {
+	repo, userID := testUser(t)
+	if err := repo.EnableTOTP(userID, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+
+	if ok, err := repo.UseRecoveryCode(userID, "hash-a"); err != nil || !ok {
+		t.Fatalf("first use = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, err := repo.UseRecoveryCode(userID, "hash-a"); err != nil || ok {
+		t.Errorf("second use = (%v, %v), want (false, nil)", ok, err)
+	}
+	if ok, err := repo.UseRecoveryCode(userID, "hash-unknown"); err != nil || ok {
+		t.Errorf("unknown code = (%v, %v), want (false, nil)", ok, err)
+	}
+	if ok, err := repo.UseRecoveryCode(userID, "hash-b"); err != nil || !ok {
+		t.Errorf("other code = (%v, %v), want (true, nil)", ok, err)
+	}
+}
+
+func TestUseTOTPStepOnlyOnce(t *testing.T) {
+	repo, userID := testUser(t)
+
+	if ok, err := repo.UseTOTPStep(userID, 100); err != nil || !ok {
+		t.Fatalf("first use = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, err := repo.UseTOTPStep(userID, 100); err != nil || ok {
+		t.Errorf("replay = (%v, %v), want (false, nil)", ok, err)
+	}
+	// A code of an earlier step, still inside the drift window, is refused as well
+	if ok, err := repo.UseTOTPStep(userID, 99); err != nil || ok {
+		t.Errorf("earlier step = (%v, %v), want (false, nil)", ok, err)
+	}
+	if ok, err := repo.UseTOTPStep(userID, 101); err != nil || !ok {
+		t.Errorf("next step = (%v, %v), want (true, nil)", ok, err)
+	}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or was issued for another sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorSortUnsupported is returned when cursor pagination is asked for with a sort other than created_at
+	ErrCursorSortUnsupported = errors.New("cursor pagination only supports sorting by created_at")
+)
+
+// OrderCursor is a position in an order listing sorted by creation time
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        int
+	Backward  bool // Whether the page before the position is wanted rather than the one after it
+}
+
+// OrderPage is a page of a cursor-paginated order listing. A cursor is empty when
+// there is no page in its direction.
+type OrderPage struct {
+	Orders     []OrderAll
+	NextCursor string
+	PrevCursor string
+}
+
+// orderCursorToken is the encoded form of a cursor. It carries the sort it was issued
+// for, as the same position means something else under the opposite sort.
+type orderCursorToken struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"i"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// encodeOrderCursor returns the opaque token clients pass back to continue from position
+func encodeOrderCursor(position OrderCursor, sort string, backward bool) string {
+	token, _ := json.Marshal(orderCursorToken{
+		Sort:      sort,
+		CreatedAt: position.CreatedAt.UTC(),
+		ID:        position.ID,
+		Backward:  backward,
+	})
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// decodeOrderCursor parses a token of encodeOrderCursor issued for the given sort
+func decodeOrderCursor(cursor, sort string) (*OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var token orderCursorToken
+	if err := json.Unmarshal(raw, &token); err != nil || token.Sort != sort || token.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &OrderCursor{CreatedAt: token.CreatedAt, ID: token.ID, Backward: token.Backward}, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	// Postgres keeps microseconds; the cursor must not lose them or the row at the
+	// boundary would be repeated or skipped
+	createdAt := time.Date(2024, 3, 9, 14, 25, 7, 123456000, time.FixedZone("BDT", 6*60*60))
+	position := OrderCursor{CreatedAt: createdAt, ID: 42}
+
+	for _, backward := range []bool{false, true} {
+		for _, sort := range []string{"created_at", "-created_at"} {
+			token := encodeOrderCursor(position, sort, backward)
+			decoded, err := decodeOrderCursor(token, sort)
+			if err != nil {
+				t.Fatalf("decoding %s cursor: %v", sort, err)
+			}
+			if !decoded.CreatedAt.Equal(createdAt) {
+				t.Errorf("created at = %v, want %v", decoded.CreatedAt, createdAt)
+			}
+			if decoded.CreatedAt.Location() != time.UTC {
+				t.Errorf("created at is in %v, want UTC", decoded.CreatedAt.Location())
+			}
+			if decoded.ID != 42 || decoded.Backward != backward {
+				t.Errorf("decoded = %+v, want id 42 and backward %v", decoded, backward)
+			}
+		}
+	}
+}
+
+func TestOrderCursorNanosecondPrecision(t *testing.T) {
+	createdAt := time.Date(2024, 3, 9, 14, 25, 7, 999999999, time.UTC)
+	decoded, err := decodeOrderCursor(encodeOrderCursor(OrderCursor{CreatedAt: createdAt, ID: 1}, "created_at", false), "created_at")
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(createdAt) {
+		t.Errorf("created at = %v, want %v", decoded.CreatedAt, createdAt)
+	}
+}
+
+func TestOrderCursorRejectsForeignSort(t *testing.T) {
+	token := encodeOrderCursor(OrderCursor{CreatedAt: time.Now(), ID: 7}, "-created_at", false)
+	if _, err := decodeOrderCursor(token, "created_at"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of another sort: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestOrderCursorRejectsTampering(t *testing.T) {
+	valid := encodeOrderCursor(OrderCursor{CreatedAt: time.Now(), ID: 7}, "created_at", false)
+
+	tampered := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("created_at,7"))},
+		{"truncated", valid[:len(valid)-4]},
+		{"bad timestamp", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","t":"yesterday","i":7}`))},
+		{"zero id", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","t":"2024-01-01T00:00:00Z","i":0}`))},
+		{"negative id", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","t":"2024-01-01T00:00:00Z","i":-3}`))},
+		{"no sort", base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-01-01T00:00:00Z","i":7}`))},
+	}
+	for _, tc := range tampered {
+		if _, err := decodeOrderCursor(tc.cursor, "created_at"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", tc.name, err)
+		}
+	}
+}
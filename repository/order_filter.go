@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OrderFilter narrows a listing of a merchant's orders. Zero values mean "no filter".
+type OrderFilter struct {
+	UserID       int // The merchant; 0 lists every merchant's orders and is for ops staff only
+	Statuses     []string
+	Archive      *bool
+	CreatedFrom  time.Time // Inclusive
+	CreatedTo    time.Time // Exclusive
+	StoreID      int
+	City         int
+	Zone         int
+	DeliveryType int
+	MinAmount    *float64 // Amount to collect, inclusive
+	MaxAmount    *float64 // Amount to collect, inclusive
+	Search       string   // Matched against recipient name and phone and merchant order ID
+	Sort         string   // See ValidOrderSort, defaults to DefaultOrderSort
+}
+
+// DefaultOrderSort lists the newest orders first
+const DefaultOrderSort = "-created_at"
+
+// orderSorts maps the accepted sort values to their ORDER BY clause. The id breaks ties
+// so pages are stable.
+var orderSorts = map[string]string{
+	"created_at":         "o.created_at ASC, o.id ASC",
+	"-created_at":        "o.created_at DESC, o.id DESC",
+	"amount_to_collect":  "o.amount_to_collect ASC, o.id ASC",
+	"-amount_to_collect": "o.amount_to_collect DESC, o.id DESC",
+	"total_fee":          "o.total_fee ASC, o.id ASC",
+	"-total_fee":         "o.total_fee DESC, o.id DESC",
+	"recipient_name":     "o.recipient_name ASC, o.id ASC",
+	"-recipient_name":    "o.recipient_name DESC, o.id DESC",
+}
+
+// ValidOrderSort reports whether sort is an accepted value of OrderFilter.Sort
+func ValidOrderSort(sort string) bool {
+	_, ok := orderSorts[sort]
+	return ok
+}
+
+// where builds the WHERE clause shared by the listing and count queries, with its
+// arguments numbered from $1
+func (f OrderFilter) where() (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.UserID != 0 {
+		add("o.userid = $%d", f.UserID)
+	}
+	if len(f.Statuses) > 0 {
+		add("o.order_status = ANY($%d)", pq.Array(f.Statuses))
+	}
+	if f.Archive != nil {
+		add("COALESCE(o.archive, false) = $%d", *f.Archive)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("o.created_at >= $%d", f.CreatedFrom.UTC())
+	}
+	if !f.CreatedTo.IsZero() {
+		add("o.created_at < $%d", f.CreatedTo.UTC())
+	}
+	if f.StoreID != 0 {
+		add("o.store_id = $%d", f.StoreID)
+	}
+	if f.City != 0 {
+		add("o.recipient_city = $%d", f.City)
+	}
+	if f.Zone != 0 {
+		add("o.recipient_zone = $%d", f.Zone)
+	}
+	if f.DeliveryType != 0 {
+		add("o.delivery_type = $%d", f.DeliveryType)
+	}
+	if f.MinAmount != nil {
+		add("o.amount_to_collect >= $%d", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		add("o.amount_to_collect <= $%d", *f.MaxAmount)
+	}
+	if f.Search != "" {
+		add("(o.recipient_name ILIKE $%[1]d OR o.recipient_phone LIKE $%[1]d OR o.merchant_order_id ILIKE $%[1]d)",
+			"%"+escapeLike(f.Search)+"%")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause of the filter's sort
+func (f OrderFilter) orderBy() string {
+	if clause, ok := orderSorts[f.Sort]; ok {
+		return "ORDER BY " + clause
+	}
+	return "ORDER BY " + orderSorts[DefaultOrderSort]
+}
+
+// escapeLike escapes the LIKE wildcards of a search term so they match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -1,15 +1,46 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"golang-orders-app/model"
 )
 
+var (
+	// ErrOrderNotFound is returned when an order does not exist or belongs to another user,
+	// so consignment IDs of other merchants cannot be enumerated
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrDuplicateMerchantOrderID is returned when a merchant order ID is already used for the store
+	ErrDuplicateMerchantOrderID = errors.New("merchant order id already used for this store")
+	// ErrMerchantOrderIDAmbiguous is returned when a merchant order ID lookup without a store matches several orders
+	ErrMerchantOrderIDAmbiguous = errors.New("merchant order id used by several stores")
+	// ErrOrderNotEditable is returned when editing an order that is no longer pending
+	ErrOrderNotEditable = errors.New("order is no longer editable")
+	// ErrOrderAlreadyCancelled is returned when cancelling an order that is already cancelled
+	ErrOrderAlreadyCancelled = errors.New("order already cancelled")
+	// ErrOrderNotCancellable is returned when cancelling an order that has already been picked up
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	// ErrInvalidStatusTransition is returned when the order lifecycle does not allow a status change
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+)
+
 // OrderRepository defines methods for interacting with the orders data.
 type OrderRepository interface {
-	CreateOrder(order *Order) (int, error)  // Method to create a new order
-	GetUser(username string) (*User, error) // Method to get an user by username
-	ListOrders(transferStatus, archive string, limit, page int, userid int) ([]OrderAll, int, error)
-	CancelOrder(consignmentID int) error
+	CreateOrder(order *Order) (int, error) // Method to create a new order
+	CreateOrders(orders []*Order, skipDuplicates bool) ([]int, error)
+	ListOrders(filter OrderFilter, limit, page int) ([]OrderAll, int, error)
+	ListOrdersByCursor(filter OrderFilter, cursor string, limit int) (*OrderPage, error)
+	ExportOrders(filter OrderFilter, fn func(OrderAll) error) error
+	GetOrder(actor *User, consignmentID int) (*OrderDetail, error)                                     // Only returns orders owned by the actor
+	GetAnyOrder(consignmentID int) (*OrderDetail, error)                                               // Unscoped lookup for ops staff
+	GetOrderByMerchantID(actor *User, storeID int, merchantOrderID string) (*OrderDetail, error)       // Only returns orders owned by the actor
+	UpdateOrder(actor *User, consignmentID int, edit func(current *OrderDetail) (*Order, error)) error // Only edits pending orders owned by the actor
+	CancelOrder(actor *User, consignmentID int) error                                                  // Only cancels orders owned by the actor
+	TransitionStatus(actor *User, consignmentID int, status, reason string) error                      // Unscoped status change for ops staff
+	GetOrderTimeline(actor *User, consignmentID int) ([]OrderEvent, error)                             // Only returns events of orders owned by the actor
+	GetAnyOrderTimeline(consignmentID int) ([]OrderEvent, error)                                       // Unscoped history with actor IDs for ops staff
+	GetTracking(trackingCode string) (*Tracking, error)                                                // Public lookup, exposes no merchant data
 }
 
 // Order represents an order in the repository layer.
@@ -38,6 +69,7 @@ type Order struct {
 	Discount           float64 `json:"discount"`
 	DeliveryFee        float64 `json:"delivery_fee"`
 	Archive            bool    `json:"archive"`
+	TrackingCode       string  `json:"tracking_code"`
 }
 
 // OrderAll represents an order response in the repository layer.
@@ -61,6 +93,66 @@ type OrderAll struct {
 	TotalFee           float64 `json:"total_fee"`
 }
 
+// OrderDetail represents every stored column of a single order.
+type OrderDetail struct {
+	ConsignmentID      int       `json:"consignment_id"`
+	MerchantID         int       `json:"merchant_id"`
+	StoreID            int       `json:"store_id"`
+	MerchantOrderID    string    `json:"merchant_order_id"`
+	RecipientName      string    `json:"recipient_name"`
+	RecipientPhone     string    `json:"recipient_phone"`
+	RecipientAddress   string    `json:"recipient_address"`
+	RecipientCity      int       `json:"recipient_city"`
+	RecipientZone      int       `json:"recipient_zone"`
+	RecipientArea      int       `json:"recipient_area"`
+	DeliveryType       int       `json:"delivery_type"`
+	ItemType           int       `json:"item_type"`
+	SpecialInstruction string    `json:"special_instruction"`
+	ItemQuantity       int       `json:"item_quantity"`
+	ItemWeight         float64   `json:"item_weight"`
+	AmountToCollect    float64   `json:"amount_to_collect"`
+	ItemDescription    string    `json:"item_description"`
+	OrderStatus        string    `json:"order_status"`
+	OrderTypeID        int       `json:"order_type_id"`
+	DeliveryFee        float64   `json:"delivery_fee"`
+	CODFee             float64   `json:"cod_fee"`
+	PromoDiscount      float64   `json:"promo_discount"`
+	Discount           float64   `json:"discount"`
+	TotalFee           float64   `json:"total_fee"`
+	Archive            bool      `json:"archive"`
+	TrackingCode       string    `json:"tracking_code"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// OrderEvent represents one status change in the history of an order.
+type OrderEvent struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"consignment_id"`
+	FromStatus string    `json:"from_status,omitempty"` // Empty for the event that created the order
+	ToStatus   string    `json:"to_status"`
+	ActorID    int       `json:"actor_id,omitempty"` // Only set for ops staff, merchants do not see who made a change
+	ActorRole  string    `json:"actor,omitempty"`    // Role of the user who made the change; "ops" or "system" for merchants
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Tracking represents what a recipient may see about their parcel.
+type Tracking struct {
+	TrackingCode     string          `json:"tracking_code"`
+	OrderStatus      string          `json:"order_status"`
+	RecipientName    string          `json:"recipient_name"`
+	RecipientPhone   string          `json:"recipient_phone"`
+	RecipientAddress string          `json:"recipient_address"`
+	Timeline         []TrackingEvent `json:"timeline"`
+}
+
+// TrackingEvent is a status change shown to recipients, without actors or internal reasons.
+type TrackingEvent struct {
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // NewOrderFromModel converts a model.Order to repository.Order
 func NewOrderFromModel(m *model.Order) *Order {
 	return &Order{
@@ -88,5 +180,6 @@ func NewOrderFromModel(m *model.Order) *Order {
 		Discount:           0.00,
 		DeliveryFee:        m.DeliveryFee,
 		Archive:            false,
+		TrackingCode:       m.TrackingCode,
 	}
 }
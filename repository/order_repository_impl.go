@@ -4,6 +4,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-orders-app/model"
+
+	"github.com/lib/pq"
 )
 
 // OrderRepositoryImpl is the struct that implements the OrderRepository interface.
@@ -21,75 +28,210 @@ func (r *OrderRepositoryImpl) CreateOrder(order *Order) (int, error) {
 	query := `INSERT INTO orders (userid, store_id, merchant_order_id, recipient_name, recipient_phone, 
     recipient_address, recipient_city, recipient_zone, recipient_area, delivery_type, item_type, 
     special_instruction, item_quantity, item_weight, amount_to_collect, item_description, order_type_id, 
-    total_fee, cod_fee, promo_discount, discount, delivery_fee, archive) 
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23) 
+    total_fee, cod_fee, promo_discount, discount, delivery_fee, archive, tracking_code) 
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24) 
     RETURNING id`
-	fmt.Println("order fee", order.TotalFee)
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
 
 	var consignmentID int
-	err := r.DB.QueryRow(query,
+	err = tx.QueryRow(query,
 		order.UserID, order.StoreID, order.MerchantOrderID, order.RecipientName,
 		order.RecipientPhone, order.RecipientAddress, order.RecipientCity, order.RecipientZone,
 		order.RecipientArea, order.DeliveryType, order.ItemType, order.SpecialInstruction,
 		order.ItemQuantity, order.ItemWeight, order.AmountToCollect, order.ItemDescription,
 		order.OrderTypeID, order.TotalFee, order.CODFee, order.PromoDiscount, order.Discount,
-		order.DeliveryFee, order.Archive,
+		order.DeliveryFee, order.Archive, order.TrackingCode,
 	).Scan(&consignmentID)
 
 	if err != nil {
+		if isUniqueViolationOf(err, merchantOrderIDIndex) {
+			return 0, ErrDuplicateMerchantOrderID
+		}
 		return 0, fmt.Errorf("error creating order: %v", err)
 	}
+
+	event := OrderEvent{OrderID: consignmentID, ToStatus: model.StatusPending, ActorID: order.UserID, Reason: "Order created"}
+	if err := recordOrderEvent(tx, event); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
 	return consignmentID, nil
 }
 
-// GetUser fetches a user from the database by username.
-func (r *OrderRepositoryImpl) GetUser(username string) (*User, error) {
-	query := `SELECT id FROM users WHERE username = $1`
-	row := r.DB.QueryRow(query, username)
+// merchantOrderIDIndex is the unique index on a merchant's references per store
+const merchantOrderIDIndex = "idx_orders_merchant_order_id"
 
-	var user User
-	if err := row.Scan(&user.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil // User not found
+// CreateOrders inserts the orders with one multi-row statement in a single transaction
+// and returns their consignment IDs in the same order. A merchant order ID already used
+// for the store fails the whole call with ErrDuplicateMerchantOrderID, unless
+// skipDuplicates is set: those orders are then left out and get a consignment ID of 0.
+func (r *OrderRepositoryImpl) CreateOrders(orders []*Order, skipDuplicates bool) ([]int, error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	const columns = 24
+	var (
+		placeholders []string
+		args         []interface{}
+	)
+	for i, order := range orders {
+		row := make([]string, columns)
+		for j := range row {
+			row[j] = fmt.Sprintf("$%d", i*columns+j+1)
 		}
-		return nil, err
+		placeholders = append(placeholders, "("+strings.Join(row, ", ")+")")
+		args = append(args,
+			order.UserID, order.StoreID, order.MerchantOrderID, order.RecipientName,
+			order.RecipientPhone, order.RecipientAddress, order.RecipientCity, order.RecipientZone,
+			order.RecipientArea, order.DeliveryType, order.ItemType, order.SpecialInstruction,
+			order.ItemQuantity, order.ItemWeight, order.AmountToCollect, order.ItemDescription,
+			order.OrderTypeID, order.TotalFee, order.CODFee, order.PromoDiscount, order.Discount,
+			order.DeliveryFee, order.Archive, order.TrackingCode,
+		)
 	}
 
-	return &user, nil
-}
+	query := `INSERT INTO orders (userid, store_id, merchant_order_id, recipient_name, recipient_phone,
+    recipient_address, recipient_city, recipient_zone, recipient_area, delivery_type, item_type,
+    special_instruction, item_quantity, item_weight, amount_to_collect, item_description, order_type_id,
+    total_fee, cod_fee, promo_discount, discount, delivery_fee, archive, tracking_code)
+    VALUES ` + strings.Join(placeholders, ", ")
+	if skipDuplicates {
+		query += `
+    ON CONFLICT (userid, store_id, merchant_order_id) WHERE merchant_order_id <> '' DO NOTHING`
+	}
+	query += `
+    RETURNING id, tracking_code`
 
-// ListOrders fetches a list of orders from the database based on the given parameters.
-func (r *OrderRepositoryImpl) ListOrders(transferStatus, archive string, limit, page int, userid int) ([]OrderAll, int, error) {
-	// Calculate offset for pagination
-	offset := (page - 1) * limit
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-	// Query to fetch orders
-	query :=
-		`SELECT                
-    o.id AS order_consignment_id,
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		if isUniqueViolationOf(err, merchantOrderIDIndex) {
+			return nil, ErrDuplicateMerchantOrderID
+		}
+		return nil, fmt.Errorf("error creating orders: %v", err)
+	}
+	// Tracking codes are unique, so they map the returned IDs back to the input order
+	ids := make(map[string]int, len(orders))
+	for rows.Next() {
+		var (
+			id           int
+			trackingCode string
+		)
+		if err := rows.Scan(&id, &trackingCode); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning order: %v", err)
+		}
+		ids[trackingCode] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		if isUniqueViolationOf(err, merchantOrderIDIndex) {
+			return nil, ErrDuplicateMerchantOrderID
+		}
+		return nil, fmt.Errorf("error creating orders: %v", err)
+	}
+
+	consignmentIDs := make([]int, len(orders))
+	for i, order := range orders {
+		consignmentIDs[i] = ids[order.TrackingCode]
+	}
+
+	eventQuery := `INSERT INTO order_events (order_id, to_status, actor_id, reason)
+    SELECT id, $2, userid, 'Order created' FROM orders WHERE id = ANY($1)`
+	if _, err := tx.Exec(eventQuery, pq.Array(consignmentIDs), model.StatusPending); err != nil {
+		return nil, fmt.Errorf("error recording order events: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return consignmentIDs, nil
+}
+
+// orderListColumns lists the columns scanned by scanOrderListing; optional columns default to zero values
+const orderListColumns = `o.id AS order_consignment_id,
     o.created_at AS order_created_at,
-    o.item_description AS order_description,
-    o.merchant_order_id,
+    COALESCE(o.item_description, '') AS order_description,
+    COALESCE(o.merchant_order_id, ''),
     o.recipient_name,
     o.recipient_address,
     o.recipient_phone,
     o.amount_to_collect AS order_amount,
-    o.delivery_fee,
-    o.cod_fee,
-    o.promo_discount,
-    o.discount,
+    COALESCE(o.delivery_fee, 0),
+    COALESCE(o.cod_fee, 0),
+    COALESCE(o.promo_discount, 0),
+    COALESCE(o.discount, 0),
     o.order_status,
     o.order_type_id,
     o.item_type,
-    o.special_instruction AS instruction,
-    o.total_fee
+    COALESCE(o.special_instruction, '') AS instruction,
+    COALESCE(o.total_fee, 0)`
+
+// scanOrderListing scans a row of orderListColumns, returning its keyset position too
+func scanOrderListing(row rowScanner) (OrderAll, OrderCursor, error) {
+	var (
+		order  OrderAll
+		cursor OrderCursor
+	)
+	err := row.Scan(
+		&cursor.ID,
+		&cursor.CreatedAt,
+		&order.OrderDescription,
+		&order.MerchantOrderID,
+		&order.RecipientName,
+		&order.RecipientAddress,
+		&order.RecipientPhone,
+		&order.OrderAmount,
+		&order.DeliveryFee,
+		&order.CODFee,
+		&order.PromoDiscount,
+		&order.Discount,
+		&order.OrderStatus,
+		&order.OrderType,
+		&order.ItemType,
+		&order.Instruction,
+		&order.TotalFee,
+	)
+	if err != nil {
+		return OrderAll{}, OrderCursor{}, err
+	}
+	order.OrderConsignmentID = strconv.Itoa(cursor.ID)
+	order.OrderCreatedAt = cursor.CreatedAt.Format(time.RFC3339Nano)
+	return order, cursor, nil
+}
+
+// ListOrders fetches a page of the orders matching the filter, along with the number of
+// matching orders across all pages.
+func (r *OrderRepositoryImpl) ListOrders(filter OrderFilter, limit, page int) ([]OrderAll, int, error) {
+	// Calculate offset for pagination
+	offset := (page - 1) * limit
+
+	// The same conditions apply to the page and to the total count
+	where, args := filter.where()
+
+	// Query to fetch orders
+	query := `SELECT ` + orderListColumns + `
 FROM orders o
-JOIN users u ON o.userid = $5
-WHERE o.order_status = $1 AND o.archive = $2 AND o.userId = $5
-LIMIT $3 OFFSET $4;
-`
+` + where + `
+` + filter.orderBy() + fmt.Sprintf(`
+LIMIT $%d OFFSET $%d;
+`, len(args)+1, len(args)+2)
 
-	rows, err := r.DB.Query(query, transferStatus, archive, limit, offset, userid)
+	rows, err := r.DB.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error fetching orders: %v", err)
 	}
@@ -97,57 +239,495 @@ LIMIT $3 OFFSET $4;
 
 	var orders []OrderAll
 	for rows.Next() {
-		var order OrderAll
-		if err := rows.Scan(
-			&order.OrderConsignmentID,
-			&order.OrderCreatedAt,
-			&order.OrderDescription,
-			&order.MerchantOrderID,
-			&order.RecipientName,
-			&order.RecipientAddress,
-			&order.RecipientPhone,
-			&order.OrderAmount,
-			&order.DeliveryFee,
-			&order.CODFee,
-			&order.PromoDiscount,
-			&order.Discount,
-			&order.OrderStatus,
-			&order.OrderType,
-			&order.ItemType,
-			&order.Instruction,
-			&order.TotalFee,
-		); err != nil {
+		order, _, err := scanOrderListing(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("error scanning order: %v", err)
 		}
 		orders = append(orders, order)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error fetching orders: %v", err)
+	}
 
 	// Count total orders for pagination
 	var total int
-	countQuery := `SELECT COUNT(*) FROM orders WHERE  order_status= $1 AND archive = $2`
-	if err := r.DB.QueryRow(countQuery, transferStatus, archive).Scan(&total); err != nil {
+	countQuery := `SELECT COUNT(*) FROM orders o ` + where
+	if err := r.DB.QueryRow(countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("error counting orders: %v", err)
 	}
 
 	return orders, total, nil
 }
 
-// CancelOrder sets the order status to "Cancelled" for the given consignment ID.
-func (r *OrderRepositoryImpl) CancelOrder(consignmentID int) error {
-	query := `UPDATE orders SET order_status = 'Cancelled' WHERE id = $1 AND order_status != 'Cancelled'`
-	result, err := r.DB.Exec(query, consignmentID)
+// ListOrdersByCursor fetches the limit orders matching the filter that follow, or with a
+// "prev" cursor precede, the position of the cursor; an empty cursor starts at the first
+// order. Pages are read from the (created_at, id) index, so their cost does not grow with
+// depth, but only the created_at sorts are supported. Cursors not issued for the filter's
+// sort are rejected with ErrInvalidCursor.
+func (r *OrderRepositoryImpl) ListOrdersByCursor(filter OrderFilter, cursor string, limit int) (*OrderPage, error) {
+	descending := filter.Sort == "-created_at"
+	if !descending && filter.Sort != "created_at" {
+		return nil, ErrCursorSortUnsupported
+	}
+
+	var position *OrderCursor
+	if cursor != "" {
+		decoded, err := decodeOrderCursor(cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		position = decoded
+	}
+	backward := position != nil && position.Backward
+
+	where, args := filter.where()
+	// A backward page is read in the opposite order, from the cursor outwards
+	ascending := descending == backward
+	if position != nil {
+		comparison := ">"
+		if !ascending {
+			comparison = "<"
+		}
+		args = append(args, position.CreatedAt.UTC(), position.ID)
+		keyset := fmt.Sprintf("(o.created_at, o.id) %s ($%d, $%d)", comparison, len(args)-1, len(args))
+		if where == "" {
+			where = "WHERE " + keyset
+		} else {
+			where += " AND " + keyset
+		}
+	}
+	orderBy := "ORDER BY o.created_at ASC, o.id ASC"
+	if !ascending {
+		orderBy = "ORDER BY o.created_at DESC, o.id DESC"
+	}
+
+	// One extra row tells whether there is a page beyond this one
+	query := `SELECT ` + orderListColumns + `
+FROM orders o
+` + where + `
+` + orderBy + fmt.Sprintf(`
+LIMIT $%d`, len(args)+1)
+
+	rows, err := r.DB.Query(query, append(args, limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching orders: %v", err)
+	}
+	defer rows.Close()
+
+	var (
+		orders    []OrderAll
+		positions []OrderCursor
+	)
+	for rows.Next() {
+		order, key, err := scanOrderListing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order: %v", err)
+		}
+		orders = append(orders, order)
+		positions = append(positions, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching orders: %v", err)
+	}
+
+	more := len(orders) > limit
+	if more {
+		orders, positions = orders[:limit], positions[:limit]
+	}
+	if backward {
+		for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+			orders[i], orders[j] = orders[j], orders[i]
+			positions[i], positions[j] = positions[j], positions[i]
+		}
+	}
+
+	page := &OrderPage{Orders: orders}
+	if len(orders) == 0 {
+		return page, nil
+	}
+	// A backward page always has a next page, the one its cursor came from; a forward
+	// page has a previous one unless it started at the beginning
+	if backward || more {
+		page.NextCursor = encodeOrderCursor(positions[len(positions)-1], filter.Sort, false)
+	}
+	if (backward && more) || (!backward && position != nil) {
+		page.PrevCursor = encodeOrderCursor(positions[0], filter.Sort, true)
+	}
+	return page, nil
+}
+
+// ExportOrders streams every order matching the filter, in the filter's sort, to fn one
+// row at a time, so large exports are never held in memory. It stops at the first error
+// returned by fn and returns it.
+func (r *OrderRepositoryImpl) ExportOrders(filter OrderFilter, fn func(OrderAll) error) error {
+	where, args := filter.where()
+	query := `SELECT ` + orderListColumns + `
+FROM orders o
+` + where + `
+` + filter.orderBy()
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error fetching orders: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order, _, err := scanOrderListing(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning order: %v", err)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error fetching orders: %v", err)
+	}
+	return nil
+}
+
+// orderDetailColumns lists the columns scanned by scanOrderDetail; optional columns default to zero values
+const orderDetailColumns = `id, userid, store_id, COALESCE(merchant_order_id, ''), recipient_name, recipient_phone,
+    recipient_address, recipient_city, recipient_zone, recipient_area, delivery_type, item_type,
+    COALESCE(special_instruction, ''), item_quantity, item_weight, amount_to_collect,
+    COALESCE(item_description, ''), COALESCE(order_status, ''), order_type_id, COALESCE(delivery_fee, 0),
+    COALESCE(cod_fee, 0), COALESCE(promo_discount, 0), COALESCE(discount, 0), COALESCE(total_fee, 0),
+    COALESCE(archive, false), tracking_code, created_at, updated_at`
+
+func scanOrderDetail(row rowScanner) (*OrderDetail, error) {
+	var order OrderDetail
+	err := row.Scan(
+		&order.ConsignmentID, &order.MerchantID, &order.StoreID, &order.MerchantOrderID, &order.RecipientName,
+		&order.RecipientPhone, &order.RecipientAddress, &order.RecipientCity, &order.RecipientZone,
+		&order.RecipientArea, &order.DeliveryType, &order.ItemType, &order.SpecialInstruction,
+		&order.ItemQuantity, &order.ItemWeight, &order.AmountToCollect, &order.ItemDescription,
+		&order.OrderStatus, &order.OrderTypeID, &order.DeliveryFee, &order.CODFee,
+		&order.PromoDiscount, &order.Discount, &order.TotalFee, &order.Archive,
+		&order.TrackingCode, &order.CreatedAt, &order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrder fetches a single order with all of its details, provided it belongs to the acting user.
+func (r *OrderRepositoryImpl) GetOrder(actor *User, consignmentID int) (*OrderDetail, error) {
+	query := `SELECT ` + orderDetailColumns + ` FROM orders WHERE id = $1 AND userid = $2`
+	order, err := scanOrderDetail(r.DB.QueryRow(query, consignmentID, actor.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+	return order, nil
+}
+
+// GetAnyOrder fetches a single order of any merchant, for ops staff.
+func (r *OrderRepositoryImpl) GetAnyOrder(consignmentID int) (*OrderDetail, error) {
+	query := `SELECT ` + orderDetailColumns + ` FROM orders WHERE id = $1`
+	order, err := scanOrderDetail(r.DB.QueryRow(query, consignmentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+	return order, nil
+}
+
+// UpdateOrder edits an order, provided it belongs to the acting user and is still
+// pending. The order is locked for the whole edit: edit is given the current order and
+// returns the order to store, so concurrent edits and status changes are applied one
+// after the other instead of overwriting each other. An error from edit aborts the
+// update and is returned as is.
+func (r *OrderRepositoryImpl) UpdateOrder(actor *User, consignmentID int, edit func(current *OrderDetail) (*Order, error)) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + orderDetailColumns + ` FROM orders WHERE id = $1 AND userid = $2 FOR UPDATE`
+	current, err := scanOrderDetail(tx.QueryRow(query, consignmentID, actor.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return fmt.Errorf("failed to fetch order: %v", err)
+	}
+
+	if current.OrderStatus != model.StatusPending {
+		return ErrOrderNotEditable
+	}
+
+	order, err := edit(current)
+	if err != nil {
+		return err
+	}
+
+	updateQuery := `UPDATE orders SET recipient_name = $3, recipient_phone = $4, recipient_address = $5,
+    recipient_city = $6, recipient_zone = $7, recipient_area = $8, delivery_type = $9, item_type = $10,
+    special_instruction = $11, item_quantity = $12, item_weight = $13, amount_to_collect = $14,
+    item_description = $15, delivery_fee = $16, cod_fee = $17, total_fee = $18, updated_at = NOW()
+    WHERE id = $1 AND userid = $2`
+	_, err = tx.Exec(updateQuery, consignmentID, actor.ID,
+		order.RecipientName, order.RecipientPhone, order.RecipientAddress,
+		order.RecipientCity, order.RecipientZone, order.RecipientArea, order.DeliveryType, order.ItemType,
+		order.SpecialInstruction, order.ItemQuantity, order.ItemWeight, order.AmountToCollect,
+		order.ItemDescription, order.DeliveryFee, order.CODFee, order.TotalFee,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// CancelOrder sets the order status to "Cancelled" for the given consignment ID,
+// provided the order belongs to the acting user and has not been picked up yet.
+func (r *OrderRepositoryImpl) CancelOrder(actor *User, consignmentID int) error {
+	tx, err := r.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to cancel order: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	status, err := lockOrderStatus(tx, consignmentID, actor.ID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch affected rows: %v", err)
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("order already cancelled or not found")
+	if status == model.StatusCancelled {
+		return ErrOrderAlreadyCancelled
+	}
+	if !model.CanCancel(status) {
+		return ErrOrderNotCancellable
+	}
+
+	if err := setOrderStatus(tx, consignmentID, model.StatusCancelled); err != nil {
+		return err
+	}
+	event := OrderEvent{OrderID: consignmentID, FromStatus: status, ToStatus: model.StatusCancelled, ActorID: actor.ID, Reason: "Cancelled by merchant"}
+	if err := recordOrderEvent(tx, event); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// TransitionStatus moves any order, regardless of its owner, to the given status
+// if the lifecycle allows it from the order's current status, and records who
+// made the change and why.
+func (r *OrderRepositoryImpl) TransitionStatus(actor *User, consignmentID int, status, reason string) error {
+	if !model.ValidOrderStatus(status) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, status)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockOrderStatus(tx, consignmentID, 0)
+	if err != nil {
+		return err
+	}
+
+	if !model.CanTransition(current, status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, current, status)
+	}
+
+	if err := setOrderStatus(tx, consignmentID, status); err != nil {
+		return err
+	}
+	event := OrderEvent{OrderID: consignmentID, FromStatus: current, ToStatus: status, ActorID: actor.ID, Reason: reason}
+	if err := recordOrderEvent(tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetOrderByMerchantID fetches the actor's order with the given merchant reference. A
+// storeID of 0 searches every store; if the reference is then used by several stores
+// ErrMerchantOrderIDAmbiguous is returned.
+func (r *OrderRepositoryImpl) GetOrderByMerchantID(actor *User, storeID int, merchantOrderID string) (*OrderDetail, error) {
+	query := `SELECT ` + orderDetailColumns + ` FROM orders
+    WHERE userid = $1 AND ($2 = 0 OR store_id = $2) AND merchant_order_id = $3 AND merchant_order_id <> ''
+    ORDER BY id LIMIT 2`
+	rows, err := r.DB.Query(query, actor.ID, storeID, merchantOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+	defer rows.Close()
+
+	var orders []*OrderDetail
+	for rows.Next() {
+		order, err := scanOrderDetail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order: %v", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+
+	switch len(orders) {
+	case 0:
+		return nil, ErrOrderNotFound
+	case 1:
+		return orders[0], nil
+	default:
+		return nil, ErrMerchantOrderIDAmbiguous
+	}
+}
+
+// GetOrderTimeline returns the status history of an order, oldest first, provided
+// the order belongs to the acting user.
+func (r *OrderRepositoryImpl) GetOrderTimeline(actor *User, consignmentID int) ([]OrderEvent, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1 AND userid = $2)`, consignmentID, actor.ID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	// Merchants see that ops or the system made a change, but not which staff member
+	query := `SELECT e.id, e.order_id, COALESCE(e.from_status, ''), e.to_status, 0,
+    CASE WHEN e.actor_id IS NULL THEN 'system' WHEN u.role IN ($2, $3) THEN $2 ELSE COALESCE(u.role, '') END,
+    COALESCE(e.reason, ''), e.created_at
+    FROM order_events e
+    LEFT JOIN users u ON u.id = e.actor_id
+    WHERE e.order_id = $1
+    ORDER BY e.created_at, e.id`
+	return r.orderEvents(query, consignmentID, RoleOps, RoleAdmin)
+}
+
+// GetAnyOrderTimeline returns the status history of any order, oldest first, with the
+// user who made each change, for ops staff.
+func (r *OrderRepositoryImpl) GetAnyOrderTimeline(consignmentID int) ([]OrderEvent, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)`, consignmentID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	query := `SELECT e.id, e.order_id, COALESCE(e.from_status, ''), e.to_status, COALESCE(e.actor_id, 0),
+    COALESCE(u.role, ''), COALESCE(e.reason, ''), e.created_at
+    FROM order_events e
+    LEFT JOIN users u ON u.id = e.actor_id
+    WHERE e.order_id = $1
+    ORDER BY e.created_at, e.id`
+	return r.orderEvents(query, consignmentID)
+}
+
+// orderEvents runs a query for the events of an order and scans them
+func (r *OrderRepositoryImpl) orderEvents(query string, args ...interface{}) ([]OrderEvent, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []OrderEvent{}
+	for rows.Next() {
+		var event OrderEvent
+		if err := rows.Scan(&event.ID, &event.OrderID, &event.FromStatus, &event.ToStatus, &event.ActorID,
+			&event.ActorRole, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning order event: %v", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// GetTracking fetches the public tracking view of the order with the given tracking code.
+func (r *OrderRepositoryImpl) GetTracking(trackingCode string) (*Tracking, error) {
+	var (
+		tracking Tracking
+		orderID  int
+	)
+	query := `SELECT id, tracking_code, order_status, recipient_name, recipient_phone, recipient_address
+    FROM orders WHERE tracking_code = $1`
+	err := r.DB.QueryRow(query, trackingCode).Scan(&orderID, &tracking.TrackingCode, &tracking.OrderStatus,
+		&tracking.RecipientName, &tracking.RecipientPhone, &tracking.RecipientAddress)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("error fetching order: %v", err)
+	}
+
+	rows, err := r.DB.Query(`SELECT to_status, created_at FROM order_events WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order events: %v", err)
+	}
+	defer rows.Close()
+
+	tracking.Timeline = []TrackingEvent{}
+	for rows.Next() {
+		var event TrackingEvent
+		if err := rows.Scan(&event.Status, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning order event: %v", err)
+		}
+		tracking.Timeline = append(tracking.Timeline, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching order events: %v", err)
+	}
+	return &tracking, nil
+}
+
+// recordOrderEvent appends a status change to the order's history
+func recordOrderEvent(db execer, event OrderEvent) error {
+	query := `INSERT INTO order_events (order_id, from_status, to_status, actor_id, reason) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.Exec(query, event.OrderID, sql.NullString{String: event.FromStatus, Valid: event.FromStatus != ""},
+		event.ToStatus, sql.NullInt64{Int64: int64(event.ActorID), Valid: event.ActorID != 0},
+		sql.NullString{String: event.Reason, Valid: event.Reason != ""})
+	if err != nil {
+		return fmt.Errorf("error recording order event: %v", err)
+	}
+	return nil
+}
+
+// lockOrderStatus locks the order row for the rest of the transaction and returns its
+// status. An ownerID of 0 matches orders of any user.
+func lockOrderStatus(tx *sql.Tx, consignmentID, ownerID int) (string, error) {
+	var status string
+	query := `SELECT order_status FROM orders WHERE id = $1 AND ($2 = 0 OR userid = $2) FOR UPDATE`
+	if err := tx.QueryRow(query, consignmentID, ownerID).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		return "", fmt.Errorf("failed to fetch order: %v", err)
+	}
+	return status, nil
+}
+
+func setOrderStatus(db execer, consignmentID int, status string) error {
+	query := `UPDATE orders SET order_status = $1, updated_at = NOW() WHERE id = $2`
+	if _, err := db.Exec(query, status, consignmentID); err != nil {
+		return fmt.Errorf("failed to update order status: %v", err)
+	}
 	return nil
 }
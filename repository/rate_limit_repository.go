@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RateLimitRepository is a throttle.WindowStore backed by Postgres, so request rate
+// limits are shared by every instance of the service.
+type RateLimitRepository struct {
+	DB *sql.DB
+}
+
+// NewRateLimitRepository initializes and returns a new RateLimitRepository
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{DB: db}
+}
+
+// Increment counts a request for key in a single statement, starting a new window if
+// the current one began period or more ago.
+func (r *RateLimitRepository) Increment(key string, now time.Time, period time.Duration) (time.Time, int, error) {
+	query := `INSERT INTO rate_limits (key, window_start, count) VALUES ($1, $2, 1)
+    ON CONFLICT (key) DO UPDATE
+    SET window_start = CASE WHEN rate_limits.window_start <= $3 THEN EXCLUDED.window_start ELSE rate_limits.window_start END,
+        count = CASE WHEN rate_limits.window_start <= $3 THEN 1 ELSE rate_limits.count + 1 END
+    RETURNING window_start, count`
+	var (
+		start time.Time
+		count int
+	)
+	now = now.UTC()
+	if err := r.DB.QueryRow(query, key, now, now.Add(-period)).Scan(&start, &count); err != nil {
+		return time.Time{}, 0, fmt.Errorf("error counting request: %v", err)
+	}
+	return start, count, nil
+}
+
+// Prune deletes windows that started before the given age.
+func (r *RateLimitRepository) Prune(age time.Duration) error {
+	if _, err := r.DB.Exec(`DELETE FROM rate_limits WHERE window_start < $1`, time.Now().UTC().Add(-age)); err != nil {
+		return fmt.Errorf("error pruning rate limits: %v", err)
+	}
+	return nil
+}
+
+// StartPruning runs Prune in the background at the given interval.
+func (r *RateLimitRepository) StartPruning(interval, age time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Prune(age); err != nil {
+				log.Printf("Failed to prune rate limits: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused is returned when a rotated-out refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken represents a stored refresh token
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	ExpiresAt time.Time
+}
+
+// RefreshTokenRepository defines methods for interacting with the refresh_tokens data.
+type RefreshTokenRepository struct {
+	DB *sql.DB
+}
+
+// NewRefreshTokenRepository initializes and returns a new RefreshTokenRepository
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{DB: db}
+}
+
+// Rotate invalidates the token matching oldHash and stores newHash in the same family.
+// Presenting a token that was already rotated out revokes the whole family and its session.
+func (r *RefreshTokenRepository) Rotate(oldHash, newHash string, expiresAt time.Time) (*RefreshToken, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		current    RefreshToken
+		revokedAt  sql.NullTime
+		replacedBy sql.NullInt64
+	)
+	query := `SELECT id, user_id, family_id, expires_at, revoked_at, replaced_by
+    FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	err = tx.QueryRow(query, oldHash).Scan(&current.ID, &current.UserID, &current.FamilyID,
+		&current.ExpiresAt, &revokedAt, &replacedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("error fetching refresh token: %v", err)
+	}
+
+	if replacedBy.Valid {
+		// The token was already exchanged, so someone else may hold its successor: the
+		// whole session ends, taking its access tokens down with it
+		if err := revokeSession(tx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("error committing transaction: %v", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if revokedAt.Valid || current.ExpiresAt.Before(time.Now()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	next := RefreshToken{UserID: current.UserID, FamilyID: current.FamilyID, ExpiresAt: expiresAt.UTC()}
+	insertQuery := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+    VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := tx.QueryRow(insertQuery, next.UserID, next.FamilyID, newHash, next.ExpiresAt).Scan(&next.ID); err != nil {
+		return nil, fmt.Errorf("error creating refresh token: %v", err)
+	}
+
+	updateQuery := `UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1 WHERE id = $2`
+	if _, err := tx.Exec(updateQuery, next.ID, current.ID); err != nil {
+		return nil, fmt.Errorf("error revoking refresh token: %v", err)
+	}
+
+	if err := touchSession(tx, next.FamilyID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return &next, nil
+}
+
+// createRefreshToken stores a refresh token of a session. Timestamps are written as UTC,
+// the way lib/pq reads TIMESTAMP columns back.
+func createRefreshToken(db execer, userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := db.Exec(query, userID, familyID, tokenHash, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("error creating refresh token: %v", err)
+	}
+	return nil
+}
+
+func revokeFamily(db execer, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := db.Exec(query, familyID); err != nil {
+		return fmt.Errorf("error revoking refresh token family: %v", err)
+	}
+	return nil
+}
+
+func revokeUserRefreshTokens(db execer, userID int) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := db.Exec(query, userID); err != nil {
+		return fmt.Errorf("error revoking user refresh tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevokedTokenRepository stores revoked access token IDs in Postgres and keeps
+// the ones it has seen in memory so repeated checks skip the database.
+type RevokedTokenRepository struct {
+	DB *sql.DB
+
+	mu    sync.RWMutex
+	cache map[string]time.Time // jti -> token expiry
+}
+
+// NewRevokedTokenRepository initializes and returns a new RevokedTokenRepository
+func NewRevokedTokenRepository(db *sql.DB) *RevokedTokenRepository {
+	return &RevokedTokenRepository{DB: db, cache: make(map[string]time.Time)}
+}
+
+// Revoke records the token ID as revoked until the token expires.
+func (r *RevokedTokenRepository) Revoke(jti string, userID int, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING`
+	if _, err := r.DB.Exec(query, jti, userID, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("error revoking token: %v", err)
+	}
+
+	r.mu.Lock()
+	r.cache[jti] = expiresAt
+	r.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the token ID has been revoked.
+func (r *RevokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	r.mu.RLock()
+	_, ok := r.cache[jti]
+	r.mu.RUnlock()
+	if ok {
+		return true, nil
+	}
+
+	// Another instance may have revoked the token
+	var expiresAt time.Time
+	query := `SELECT expires_at FROM revoked_tokens WHERE jti = $1`
+	if err := r.DB.QueryRow(query, jti).Scan(&expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking revoked token: %v", err)
+	}
+
+	r.mu.Lock()
+	r.cache[jti] = expiresAt
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Prune removes revocations for tokens that have already expired.
+func (r *RevokedTokenRepository) Prune() error {
+	now := time.Now()
+	if _, err := r.DB.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now.UTC()); err != nil {
+		return fmt.Errorf("error pruning revoked tokens: %v", err)
+	}
+
+	r.mu.Lock()
+	for jti, expiresAt := range r.cache {
+		if expiresAt.Before(now) {
+			delete(r.cache, jti)
+		}
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// StartPruning runs Prune in the background at the given interval.
+func (r *RevokedTokenRepository) StartPruning(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Prune(); err != nil {
+				log.Printf("Failed to prune revoked tokens: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound is returned when a session does not exist, is already revoked or belongs to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limits how often last_used_at is written for a busy session
+const sessionTouchInterval = time.Minute
+
+// Session represents one login of a user. Its ID is the family ID of the refresh
+// tokens rotated from that login and the sid claim of its access tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"` // Whether the listing request was made with this session
+}
+
+// SessionRepository defines methods for interacting with the sessions data.
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+// NewSessionRepository initializes and returns a new SessionRepository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// Start records a new session together with its first refresh token.
+func (r *SessionRepository) Start(session Session, refreshTokenHash string, expiresAt time.Time) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip_address) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(query, session.ID, session.UserID, session.UserAgent, session.IPAddress); err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+
+	if err := createRefreshToken(tx, session.UserID, session.ID, refreshTokenHash, expiresAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// CheckActive reports whether the session exists and has not been revoked, and
+// records that it was used.
+func (r *SessionRepository) CheckActive(id string) (bool, error) {
+	var (
+		lastUsedAt time.Time
+		revokedAt  sql.NullTime
+	)
+	query := `SELECT last_used_at, revoked_at FROM sessions WHERE id = $1`
+	if err := r.DB.QueryRow(query, id).Scan(&lastUsedAt, &revokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error fetching session: %v", err)
+	}
+	if revokedAt.Valid {
+		return false, nil
+	}
+
+	// Written at most once per interval to keep authenticated reads cheap
+	if time.Since(lastUsedAt) > sessionTouchInterval {
+		if err := touchSession(r.DB, id); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ListActive returns the user's sessions that have not been revoked, most recently used first.
+func (r *SessionRepository) ListActive(userID int) ([]Session, error) {
+	query := `SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_used_at
+    FROM sessions WHERE user_id = $1 AND revoked_at IS NULL
+    ORDER BY last_used_at DESC`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sessions: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of the user's sessions and revokes its refresh tokens.
+func (r *SessionRepository) Revoke(userID int, id string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to fetch affected rows: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	if err := revokeFamily(tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func revokeUserSessions(db execer, userID int) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := db.Exec(query, userID); err != nil {
+		return fmt.Errorf("error revoking user sessions: %v", err)
+	}
+	return revokeUserRefreshTokens(db, userID)
+}
+
+// revokeSession ends a session, whoever it belongs to, and revokes its refresh tokens
+func revokeSession(db execer, id string) error {
+	if _, err := db.Exec(`UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id); err != nil {
+		return fmt.Errorf("error revoking session: %v", err)
+	}
+	return revokeFamily(db, id)
+}
+
+func touchSession(db execer, id string) error {
+	if _, err := db.Exec(`UPDATE sessions SET last_used_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
@@ -3,13 +3,38 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrUsernameTaken is returned when registering a username that already exists
+	ErrUsernameTaken = errors.New("username already taken")
+	// ErrVerificationTokenInvalid is returned for unknown, used or expired verification tokens
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	// ErrPasswordResetTokenInvalid is returned for unknown, used or expired password reset tokens
+	ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or expired")
+)
+
+// Roles a user can have
+const (
+	RoleMerchant = "merchant"
+	RoleOps      = "ops"
+	RoleAdmin    = "admin"
+	RoleRider    = "rider"
 )
 
 // User represents a user in the system
 type User struct {
-	ID       int
-	Username string
-	Password string
+	ID              int
+	Username        string
+	Role            string
+	Password        string // Legacy plaintext password, empty once PasswordHash is set
+	PasswordHash    string
+	TokensRevokedAt *time.Time // Access tokens issued before this time are no longer accepted
+	VerifiedAt      *time.Time // Nil until the account has been verified
+	TOTPSecret      string     // Set while enrolling in and after enabling two-factor authentication
+	TOTPEnabledAt   *time.Time // Nil unless logins require a TOTP code
 }
 
 // UserRepository defines methods for interacting with the users data.
@@ -22,13 +47,15 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-// GetUserByUsername fetches a user from the database by username.
-func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
-	query := `SELECT id, username, password FROM users WHERE username = $1`
-	row := r.DB.QueryRow(query, username)
+// userColumns lists the columns scanned by scanUser, in order
+const userColumns = `id, username, role, COALESCE(password, ''), COALESCE(password_hash, ''), tokens_revoked_at, verified_at,
+    COALESCE(totp_secret, ''), totp_enabled_at`
 
+// scanUser scans a row selected with userColumns, returning nil if there is no row.
+func scanUser(row *sql.Row) (*User, error) {
 	var user User
-	if err := row.Scan(&user.ID, &user.Username, &user.Password); err != nil {
+	if err := row.Scan(&user.ID, &user.Username, &user.Role, &user.Password, &user.PasswordHash, &user.TokensRevokedAt, &user.VerifiedAt,
+		&user.TOTPSecret, &user.TOTPEnabledAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // User not found
 		}
@@ -37,3 +64,183 @@ func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
 
 	return &user, nil
 }
+
+// GetUserByUsername fetches a user from the database by username, ignoring case.
+func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(username) = LOWER($1)`
+	return scanUser(r.DB.QueryRow(query, username))
+}
+
+// GetUserByID fetches a user from the database by id.
+func (r *UserRepository) GetUserByID(id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.DB.QueryRow(query, id))
+}
+
+// RevokeAllAccess logs the user out everywhere, all in one transaction: it rejects every
+// access token issued so far, ends every session with its refresh tokens and revokes
+// every API key, since keys may have been created with a stolen token.
+func (r *UserRepository) RevokeAllAccess(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserTokens(tx, userID); err != nil {
+		return err
+	}
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return err
+	}
+	if err := revokeUserAPIKeys(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func revokeUserTokens(db execer, userID int) error {
+	// Stored as UTC so it compares correctly with the token's issued-at claim
+	query := `UPDATE users SET tokens_revoked_at = $2 WHERE id = $1`
+	if _, err := db.Exec(query, userID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("error revoking user tokens: %v", err)
+	}
+	return nil
+}
+
+// SetPasswordHash stores a new password hash for the user and clears any legacy plaintext password.
+func (r *UserRepository) SetPasswordHash(userID int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, password = NULL WHERE id = $1`
+	if _, err := r.DB.Exec(query, userID, passwordHash); err != nil {
+		return fmt.Errorf("error updating password: %v", err)
+	}
+	return nil
+}
+
+// CreateUnverifiedUser creates a user that must be verified before logging in, together
+// with its first verification token.
+func (r *UserRepository) CreateUnverifiedUser(username, passwordHash, tokenHash string, expiresAt time.Time) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `INSERT INTO users (username, password_hash) VALUES ($1, $2) RETURNING id`
+	if err := tx.QueryRow(query, username, passwordHash).Scan(&userID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrUsernameTaken
+		}
+		return 0, fmt.Errorf("error creating user: %v", err)
+	}
+
+	if err := createVerificationToken(tx, userID, tokenHash, expiresAt); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return userID, nil
+}
+
+// CreateVerificationToken stores a new verification token for the user.
+func (r *UserRepository) CreateVerificationToken(userID int, tokenHash string, expiresAt time.Time) error {
+	return createVerificationToken(r.DB, userID, tokenHash, expiresAt)
+}
+
+func createVerificationToken(db execer, userID int, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO verification_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	if _, err := db.Exec(query, userID, tokenHash, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("error creating verification token: %v", err)
+	}
+	return nil
+}
+
+// VerifyUser consumes the verification token and marks its user as verified.
+func (r *UserRepository) VerifyUser(tokenHash string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `UPDATE verification_tokens SET used_at = NOW()
+    WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+    RETURNING user_id`
+	if err := tx.QueryRow(query, tokenHash, time.Now().UTC()).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVerificationTokenInvalid
+		}
+		return fmt.Errorf("error consuming verification token: %v", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET verified_at = NOW() WHERE id = $1 AND verified_at IS NULL`, userID); err != nil {
+		return fmt.Errorf("error verifying user: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// CreatePasswordResetToken stores a new password reset token for the user.
+func (r *UserRepository) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	if _, err := r.DB.Exec(query, userID, tokenHash, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("error creating password reset token: %v", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the reset token, stores the new password hash and revokes
+// every session and API key of the user, all in one transaction. It returns the user's ID.
+func (r *UserRepository) ResetPassword(tokenHash, passwordHash string) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `UPDATE password_reset_tokens SET used_at = NOW()
+    WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+    RETURNING user_id`
+	if err := tx.QueryRow(query, tokenHash, time.Now().UTC()).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrPasswordResetTokenInvalid
+		}
+		return 0, fmt.Errorf("error consuming password reset token: %v", err)
+	}
+
+	// Any other outstanding reset token for the account is void once the password changes
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return 0, fmt.Errorf("error invalidating password reset tokens: %v", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET password_hash = $2, password = NULL WHERE id = $1`, userID, passwordHash); err != nil {
+		return 0, fmt.Errorf("error updating password: %v", err)
+	}
+
+	if err := revokeUserTokens(tx, userID); err != nil {
+		return 0, err
+	}
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return 0, err
+	}
+	if err := revokeUserAPIKeys(tx, userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetUserByUsernameIgnoresCase(t *testing.T) {
+	repo, userID := testUser(t)
+	stored, err := repo.GetUserByID(userID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetUserByID = (%v, %v)", stored, err)
+	}
+
+	user, err := repo.GetUserByUsername(strings.ToUpper(stored.Username))
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if user == nil || user.ID != userID {
+		t.Errorf("GetUserByUsername(upper case) = %v, want user %d", user, userID)
+	}
+}
+
+func TestCreateUnverifiedUserRejectsCaseVariant(t *testing.T) {
+	repo, userID := testUser(t)
+	stored, err := repo.GetUserByID(userID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetUserByID = (%v, %v)", stored, err)
+	}
+
+	_, err = repo.CreateUnverifiedUser(strings.ToUpper(stored.Username), "hash", "token-variant-"+stored.Username, time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("CreateUnverifiedUser(upper case) error = %v, want ErrUsernameTaken", err)
+	}
+}
@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold is the number of entries above which stale ones are dropped
+const sweepThreshold = 10000
+
+// MemoryStore keeps entries in process memory. It is the default for a single instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// Get returns the entry for key
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+// RecordFailure replaces the entry for key with update(current)
+func (s *MemoryStore) RecordFailure(key string, update func(Entry) Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) > sweepThreshold {
+		s.sweep(time.Now())
+	}
+
+	entry := update(s.entries[key])
+	s.entries[key] = entry
+	return entry, nil
+}
+
+// Reset forgets the entry for key
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops entries that are no longer locked and have not failed for an hour
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if entry.LockedUntil.Before(now) && now.Sub(entry.LastFailure) > time.Hour {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// WindowStore counts the requests of each key in fixed windows. Like Store, it can be
+// backed by a database so that every instance shares the same counts.
+type WindowStore interface {
+	// Increment counts a request for key at now, first starting a new window if the
+	// current one began period or more ago. It returns the start of the window and the
+	// number of requests counted in it, this one included.
+	Increment(key string, now time.Time, period time.Duration) (time.Time, int, error)
+}
+
+// RateLimiter allows a fixed number of requests per key in each window
+type RateLimiter struct {
+	limit  int
+	period time.Duration
+	store  WindowStore
+}
+
+// NewRateLimiter returns a RateLimiter allowing limit requests per key every period
+func NewRateLimiter(limit int, period time.Duration, store WindowStore) *RateLimiter {
+	return &RateLimiter{limit: limit, period: period, store: store}
+}
+
+// Allow counts a request for key and returns how long to wait if it is over the limit
+func (l *RateLimiter) Allow(key string) (time.Duration, error) {
+	now := time.Now()
+	start, count, err := l.store.Increment(key, now, l.period)
+	if err != nil {
+		return 0, err
+	}
+	if count > l.limit {
+		return start.Add(l.period).Sub(now), nil
+	}
+	return 0, nil
+}
+
+// window counts the requests of one key in the current fixed window
+type window struct {
+	start time.Time
+	count int
+}
+
+// MemoryWindowStore keeps windows in process memory. It is the default for a single instance.
+type MemoryWindowStore struct {
+	mu      sync.Mutex
+	windows map[string]window
+}
+
+// NewMemoryWindowStore returns an empty MemoryWindowStore
+func NewMemoryWindowStore() *MemoryWindowStore {
+	return &MemoryWindowStore{windows: make(map[string]window)}
+}
+
+// Increment counts a request for key
+func (s *MemoryWindowStore) Increment(key string, now time.Time, period time.Duration) (time.Time, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.windows) > sweepThreshold {
+		for k, w := range s.windows {
+			if now.Sub(w.start) >= period {
+				delete(s.windows, k)
+			}
+		}
+	}
+
+	w := s.windows[key]
+	if now.Sub(w.start) >= period {
+		w = window{start: now}
+	}
+	w.count++
+	s.windows[key] = w
+	return w.start, w.count, nil
+}
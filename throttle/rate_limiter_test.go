@@ -0,0 +1,44 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryWindowStoreIncrement(t *testing.T) {
+	store := NewMemoryWindowStore()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		got, count, _ := store.Increment("ip", start.Add(time.Duration(i)*time.Second), time.Minute)
+		if count != i || !got.Equal(start.Add(time.Second)) {
+			t.Fatalf("request %d = (%v, %d), want the window of the first request and count %d", i, got, count, i)
+		}
+	}
+	if _, count, _ := store.Increment("other", start, time.Minute); count != 1 {
+		t.Errorf("other key count = %d, want 1", count)
+	}
+
+	// A request a full period after the window started opens a new one
+	next := start.Add(time.Second + time.Minute)
+	if got, count, _ := store.Increment("ip", next, time.Minute); count != 1 || !got.Equal(next) {
+		t.Errorf("after the window = (%v, %d), want a new window at %v", got, count, next)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute, NewMemoryWindowStore())
+
+	for i := 0; i < 2; i++ {
+		if wait, err := limiter.Allow("ip"); err != nil || wait != 0 {
+			t.Fatalf("request %d = (%v, %v), want allowed", i+1, wait, err)
+		}
+	}
+	wait, err := limiter.Allow("ip")
+	if err != nil || wait <= 0 || wait > time.Minute {
+		t.Errorf("request over the limit = (%v, %v), want a wait of up to a minute", wait, err)
+	}
+	if wait, _ := limiter.Allow("other"); wait != 0 {
+		t.Errorf("other key waited %v", wait)
+	}
+}
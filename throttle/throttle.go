@@ -0,0 +1,120 @@
+package throttle
+
+import (
+	"strings"
+	"time"
+)
+
+// Entry is the failed login state tracked for one username or client IP
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists entries so they can be shared between instances
+type Store interface {
+	// Get returns the entry for key, or a zero Entry if there is none
+	Get(key string) (Entry, error)
+	// RecordFailure atomically replaces the entry for key with update(current)
+	RecordFailure(key string, update func(Entry) Entry) (Entry, error)
+	// Reset forgets the entry for key
+	Reset(key string) error
+}
+
+// Policy decides when repeated failures lock a key out
+type Policy struct {
+	FreeAttempts int           // Failures allowed before lockouts start
+	BaseLockout  time.Duration // Lockout after the first failure past FreeAttempts, doubled on every further failure
+	MaxLockout   time.Duration // Upper bound of a single lockout
+	Window       time.Duration // Failures are forgotten after this long without a new one
+}
+
+// DefaultAccountPolicy applies to failed logins for one username
+var DefaultAccountPolicy = Policy{FreeAttempts: 5, BaseLockout: 30 * time.Second, MaxLockout: 15 * time.Minute, Window: 15 * time.Minute}
+
+// DefaultIPPolicy applies to failed logins from one client IP, across usernames
+var DefaultIPPolicy = Policy{FreeAttempts: 20, BaseLockout: 30 * time.Second, MaxLockout: time.Hour, Window: 15 * time.Minute}
+
+// Throttle tracks failed logins per username and per client IP
+type Throttle struct {
+	store         Store
+	accountPolicy Policy
+	ipPolicy      Policy
+}
+
+// New returns a Throttle using the default policies
+func New(store Store) *Throttle {
+	return &Throttle{store: store, accountPolicy: DefaultAccountPolicy, ipPolicy: DefaultIPPolicy}
+}
+
+// Check returns how long the caller must wait before trying to log in again, zero if it may try now
+func (t *Throttle) Check(username, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(username), ipKey(ip)} {
+		entry, err := t.store.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		if d := entry.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Failure records a failed login and returns how long the caller must now wait
+func (t *Throttle) Failure(username, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for key, policy := range map[string]Policy{accountKey(username): t.accountPolicy, ipKey(ip): t.ipPolicy} {
+		policy := policy
+		entry, err := t.store.RecordFailure(key, func(entry Entry) Entry {
+			return policy.next(entry, now)
+		})
+		if err != nil {
+			return 0, err
+		}
+		if d := entry.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Success clears the failures of the username. The IP is left alone so that one
+// valid account cannot be used to reset the counter of an IP guessing others.
+func (t *Throttle) Success(username string) error {
+	return t.store.Reset(accountKey(username))
+}
+
+// next returns the entry after one more failure at now
+func (p Policy) next(entry Entry, now time.Time) Entry {
+	if now.Sub(entry.LastFailure) > p.Window && entry.LockedUntil.Before(now) {
+		entry = Entry{}
+	}
+
+	entry.Failures++
+	entry.LastFailure = now
+
+	if over := entry.Failures - p.FreeAttempts; over > 0 {
+		lockout := p.BaseLockout
+		for i := 1; i < over && lockout < p.MaxLockout; i++ {
+			lockout *= 2
+		}
+		if lockout > p.MaxLockout {
+			lockout = p.MaxLockout
+		}
+		entry.LockedUntil = now.Add(lockout)
+	}
+	return entry
+}
+
+func accountKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,145 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{FreeAttempts: 3, BaseLockout: 10 * time.Second, MaxLockout: time.Minute, Window: 5 * time.Minute}
+
+func TestPolicyNextBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Lockouts start after the free attempts and double up to the maximum
+	want := []time.Duration{0, 0, 0, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	var entry Entry
+	for i, lockout := range want {
+		entry = testPolicy.next(entry, now)
+		if entry.Failures != i+1 {
+			t.Fatalf("failure %d: counted %d failures", i+1, entry.Failures)
+		}
+		if !entry.LastFailure.Equal(now) {
+			t.Errorf("failure %d: last failure = %v, want %v", i+1, entry.LastFailure, now)
+		}
+		var got time.Duration
+		if !entry.LockedUntil.IsZero() {
+			got = entry.LockedUntil.Sub(now)
+		}
+		if got != lockout {
+			t.Errorf("failure %d: lockout = %v, want %v", i+1, got, lockout)
+		}
+	}
+}
+
+func TestPolicyNextLockoutNeverOverflows(t *testing.T) {
+	now := time.Now()
+	entry := Entry{Failures: 1000, LastFailure: now}
+	entry = testPolicy.next(entry, now)
+	if got := entry.LockedUntil.Sub(now); got != testPolicy.MaxLockout {
+		t.Errorf("lockout after 1001 failures = %v, want %v", got, testPolicy.MaxLockout)
+	}
+}
+
+func TestPolicyNextWindowReset(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := Entry{Failures: 2, LastFailure: start}
+
+	// Inside the window failures keep counting
+	inside := testPolicy.next(entry, start.Add(testPolicy.Window))
+	if inside.Failures != 3 {
+		t.Errorf("failure at the end of the window counted as %d, want 3", inside.Failures)
+	}
+
+	// After a quiet window the count starts over
+	after := testPolicy.next(entry, start.Add(testPolicy.Window+time.Second))
+	if after.Failures != 1 || !after.LockedUntil.IsZero() {
+		t.Errorf("failure after the window = %+v, want a fresh count of 1", after)
+	}
+
+	// A lockout still running is never cut short by the window
+	locked := Entry{Failures: 10, LastFailure: start, LockedUntil: start.Add(time.Hour)}
+	during := testPolicy.next(locked, start.Add(testPolicy.Window+time.Second))
+	if during.Failures != 11 {
+		t.Errorf("failure during a lockout counted as %d, want 11", during.Failures)
+	}
+}
+
+func newTestThrottle() (*Throttle, *MemoryStore) {
+	store := NewMemoryStore()
+	ipPolicy := testPolicy
+	ipPolicy.FreeAttempts = 5
+	return &Throttle{store: store, accountPolicy: testPolicy, ipPolicy: ipPolicy}, store
+}
+
+func TestThrottleLocksAccountAndIP(t *testing.T) {
+	th, _ := newTestThrottle()
+
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		wait, err := th.Failure("alice@example.com", "10.0.0.1")
+		if err != nil || wait != 0 {
+			t.Fatalf("free failure %d: wait = %v, err = %v", i+1, wait, err)
+		}
+	}
+	wait, err := th.Failure("alice@example.com", "10.0.0.1")
+	if err != nil || wait <= 0 || wait > testPolicy.BaseLockout {
+		t.Fatalf("first locked failure: wait = %v, err = %v", wait, err)
+	}
+
+	// The account is locked from any IP, and usernames are matched like at login
+	if wait, _ := th.Check(" Alice@Example.com ", "10.0.0.2"); wait <= 0 {
+		t.Error("the account is not locked from another IP")
+	}
+	// Another account from the same IP is fine until the IP runs out of attempts
+	if wait, _ := th.Check("bob@example.com", "10.0.0.1"); wait != 0 {
+		t.Errorf("another account from the same IP must wait %v", wait)
+	}
+	// Four failures so far; the IP is allowed five before its sixth locks it
+	th.Failure("bob@example.com", "10.0.0.1")
+	if wait, _ := th.Check("carol@example.com", "10.0.0.1"); wait != 0 {
+		t.Error("the IP is locked within its free attempts")
+	}
+	th.Failure("bob@example.com", "10.0.0.1")
+	if wait, _ := th.Check("carol@example.com", "10.0.0.1"); wait <= 0 {
+		t.Error("the IP is not locked after its free attempts across accounts")
+	}
+}
+
+func TestThrottleSuccessResetsOnlyTheAccount(t *testing.T) {
+	th, store := newTestThrottle()
+	for i := 0; i < 6; i++ {
+		th.Failure("alice@example.com", "10.0.0.1")
+	}
+
+	if err := th.Success("alice@example.com"); err != nil {
+		t.Fatalf("Success: %v", err)
+	}
+	if entry, _ := store.Get(accountKey("alice@example.com")); entry.Failures != 0 {
+		t.Errorf("account entry after success = %+v, want none", entry)
+	}
+	// The IP keeps its count and lockout
+	if entry, _ := store.Get(ipKey("10.0.0.1")); entry.Failures != 6 || entry.LockedUntil.IsZero() {
+		t.Errorf("IP entry after success = %+v, want 6 failures and a lockout", entry)
+	}
+	if wait, _ := th.Check("alice@example.com", "10.0.0.1"); wait <= 0 {
+		t.Error("a success cleared the IP lockout")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.entries["stale"] = Entry{Failures: 3, LastFailure: now.Add(-2 * time.Hour)}
+	store.entries["recent"] = Entry{Failures: 3, LastFailure: now.Add(-time.Minute)}
+	store.entries["locked"] = Entry{Failures: 30, LastFailure: now.Add(-2 * time.Hour), LockedUntil: now.Add(time.Hour)}
+
+	store.sweep(now)
+
+	if _, ok := store.entries["stale"]; ok {
+		t.Error("a stale entry was kept")
+	}
+	for _, key := range []string{"recent", "locked"} {
+		if _, ok := store.entries[key]; !ok {
+			t.Errorf("entry %q was swept", key)
+		}
+	}
+}
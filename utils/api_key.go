@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// apiKeyPrefix marks strings as API keys of this service
+const apiKeyPrefix = "ok_"
+
+// apiKeyPrefixBytes is the number of random bytes in the public part of a key. It is
+// looked up under a unique index, so it must be long enough to never collide.
+const apiKeyPrefixBytes = 8
+
+// GenerateAPIKey returns a new API key of the form "<prefix>.<secret>" together with its two parts.
+// Only the prefix and the hash of the secret should be stored.
+func GenerateAPIKey() (key, prefix, secret string, err error) {
+	b := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(b)
+
+	secret, err = GenerateRandomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	return prefix + "." + secret, prefix, secret, nil
+}
+
+// SplitAPIKey splits an API key into its prefix and secret parts
+func SplitAPIKey(key string) (prefix, secret string, ok bool) {
+	prefix, secret, ok = strings.Cut(key, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefix) || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the IP address of the client that sent the request.
+// Proxy headers are only honoured when a RealIP middleware has rewritten RemoteAddr.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -3,40 +3,66 @@ package utils
 import (
 	"errors"
 	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// AccessTokenTTL is how long an access token issued by GenerateJWT stays valid
+const AccessTokenTTL = 5 * time.Hour
+
+// MFATokenTTL is how long a challenge token issued by GenerateMFAToken stays valid
+const MFATokenTTL = 5 * time.Minute
+
+// PurposeMFA marks a challenge token that can only be exchanged for an access token
+// together with a valid second factor
+const PurposeMFA = "mfa"
+
+// errNoKeySet is returned when tokens are used before SetKeySet has been called
+var errNoKeySet = errors.New("jwt key set is not configured")
+
 // Define a struct to hold the JWT claims
 type Claims struct {
-	Username string `json:"username"`
+	Username  string `json:"username"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`     // Refresh token family the token was issued from
+	Purpose   string `json:"purpose,omitempty"` // Empty for access tokens
 	jwt.RegisteredClaims
 }
 
 // GenerateJWT generates a JWT for the user after login
-func GenerateJWT(username string) (string, error) {
-	// Set the expiration time for the token (e.g., 5 days)
-	expirationTime := time.Now().Add(5 * time.Hour)
-
-	// Create the claims
-	claims := &Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			Issuer:    "yourAppName", // You can set your application name or another identifier here
-		},
+func GenerateJWT(username, role, sessionID string) (string, error) {
+	return signToken(&Claims{Username: username, Role: role, SessionID: sessionID}, AccessTokenTTL)
+}
+
+// GenerateMFAToken generates a short-lived challenge token for a user who has
+// passed the password check but still has to provide a second factor
+func GenerateMFAToken(username string) (string, error) {
+	return signToken(&Claims{Username: username, Purpose: PurposeMFA}, MFATokenTTL)
+}
+
+// signToken stamps the registered claims and signs the token with the active key
+func signToken(claims *Claims, ttl time.Duration) (string, error) {
+	if keySet == nil {
+		return "", errNoKeySet
 	}
 
-	// Create a new token with the claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Every token gets a unique ID so it can be revoked on its own
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
 
-	// Sign the token with a secret key (you can store this key in an environment variable)
-	secretKey := os.Getenv("JWT_SECRET_KEY") // It's better to load the key from the environment for security
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		Issuer:    "yourAppName", // You can set your application name or another identifier here
+	}
 
-	// Generate the signed token string
-	tokenString, err := token.SignedString([]byte(secretKey))
+	// Sign the token with the active key of the key set
+	tokenString, err := keySet.Sign(claims)
 	if err != nil {
 		log.Println("Error signing the token:", err)
 		return "", err
@@ -45,15 +71,44 @@ func GenerateJWT(username string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates the JWT token
+// ValidateToken validates an access token
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Challenge tokens must never be accepted as access tokens
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// ValidateMFAToken validates a challenge token issued by GenerateMFAToken
+func ValidateMFAToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// parseToken verifies the signature and expiry of a token
+func parseToken(tokenString string) (*Claims, error) {
 	// Parse the token
 	claims := &Claims{}
-	secretKey := os.Getenv("JWT_SECRET_KEY")
+	if keySet == nil {
+		return nil, errNoKeySet
+	}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keySet.Keyfunc, jwt.WithValidMethods(keySet.Methods()))
 
 	if err != nil {
 		return nil, err
@@ -64,7 +119,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	// Check token expiration
-	if claims.ExpiresAt.Time.Before(time.Now()) {
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Time.Before(time.Now()) {
 		return nil, errors.New("token has expired")
 	}
 
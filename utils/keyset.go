@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// legacyKeyID identifies the HMAC key built from JWT_SECRET_KEY. Tokens signed
+// before key IDs were introduced carry no kid and are verified with it.
+const legacyKeyID = "hs256"
+
+// SigningKey is a key tokens are signed or verified with
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{} // Nil for keys that only verify tokens signed before a rotation
+	Public  interface{}
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens are signed with
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// LoadKeySet builds the key set from the PEM files in dir and the HMAC secret.
+//
+// Each "<kid>.pem" file in dir holds an RSA (RS256) or Ed25519 (EdDSA) key. Private
+// keys can sign; public keys only verify, which keeps tokens signed by a retired key
+// valid until they expire. activeKID selects the signing key. Without a key directory
+// tokens are signed with the HMAC secret, which must then be set.
+//
+// With a key directory the HMAC secret is ignored, as anyone who knows it could mint
+// tokens, unless acceptLegacyHS256 is set: it then still verifies, but never signs,
+// tokens issued before the switch. That is only meant for the first AccessTokenTTL
+// after switching.
+func LoadKeySet(dir, activeKID, hmacSecret string, acceptLegacyHS256 bool) (*KeySet, error) {
+	if acceptLegacyHS256 && (hmacSecret == "" || dir == "") {
+		return nil, errors.New("JWT_ACCEPT_LEGACY_HS256 needs both JWT_SECRET_KEY and JWT_KEYS_DIR")
+	}
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+
+	switch {
+	case dir == "" && hmacSecret != "":
+		ks.keys[legacyKeyID] = &SigningKey{
+			ID:      legacyKeyID,
+			Method:  jwt.SigningMethodHS256,
+			Private: []byte(hmacSecret),
+			Public:  []byte(hmacSecret),
+		}
+	case acceptLegacyHS256:
+		// Verification only, so no new HS256 token is ever issued
+		ks.keys[legacyKeyID] = &SigningKey{
+			ID:     legacyKeyID,
+			Method: jwt.SigningMethodHS256,
+			Public: []byte(hmacSecret),
+		}
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			key, err := loadPEMKey(file)
+			if err != nil {
+				return nil, fmt.Errorf("error loading signing key %s: %v", file, err)
+			}
+			ks.keys[key.ID] = key
+		}
+	}
+
+	if activeKID == "" && dir == "" {
+		activeKID = legacyKeyID
+	}
+
+	active, ok := ks.keys[activeKID]
+	if !ok {
+		if activeKID == legacyKeyID {
+			return nil, errors.New("JWT_SECRET_KEY must be set when no signing key directory is configured")
+		}
+		return nil, fmt.Errorf("active signing key %q not found", activeKID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", activeKID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// loadPEMKey parses an RSA or Ed25519, private or public key named after its kid
+func loadPEMKey(file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key := &SigningKey{ID: strings.TrimSuffix(filepath.Base(file), ".pem")}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, private, &private.PublicKey
+		return key, nil
+	}
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		edPrivate := private.(ed25519.PrivateKey)
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, edPrivate, edPrivate.Public()
+		return key, nil
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		key.Method, key.Public = jwt.SigningMethodRS256, public
+		return key, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		key.Method, key.Public = jwt.SigningMethodEdDSA, public
+		return key, nil
+	}
+	return nil, errors.New("unsupported key, expected an RSA or Ed25519 key in PEM format")
+}
+
+// Sign signs the claims with the active key and stamps its kid in the header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.Private)
+}
+
+// Keyfunc returns the key a token must be verified with, making sure the token's
+// algorithm is the one the key belongs to
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// Methods lists the algorithms of the keys in the set
+func (ks *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys of the set. HMAC keys are secret and never published.
+func (ks *KeySet) JWKS() []JWK {
+	jwks := []JWK{}
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].KeyID < jwks[j].KeyID })
+	return jwks
+}
+
+// keySet is the key set used by GenerateJWT and ValidateToken
+var keySet *KeySet
+
+// SetKeySet installs the key set used to sign and verify tokens
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when a user does not exist, so a failed
+// login takes the same time whether or not the username is known
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// HashPassword returns the bcrypt hash of the password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the bcrypt hash, in constant time
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// CheckLegacyPassword compares a plaintext password stored before hashing was introduced, in constant time
+func CheckLegacyPassword(stored, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// BurnPasswordCheck spends the same time as CheckPassword without a real hash to compare against
+func BurnPasswordCheck(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// ValidatePassword checks the password against the password policy and returns
+// the rules it breaks, in the format used for field validation errors
+func ValidatePassword(password string) []string {
+	var problems []string
+	if len(password) < 8 {
+		problems = append(problems, "The password must be at least 8 characters")
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		problems = append(problems, "The password may not be greater than 72 characters")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		problems = append(problems, "The password must contain at least one letter and one number")
+	}
+	return problems
+}
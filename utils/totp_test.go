@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+
+	// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		want := v.code[2:]
+		if got := totpCode(key, v.unix/totpPeriod); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", v.unix, got, want)
+		}
+		step, ok := MatchTOTP(rfc6238Secret, want, time.Unix(v.unix, 0))
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("MatchTOTP at %d = (%d, %v), want (%d, true)", v.unix, step, ok, v.unix/totpPeriod)
+		}
+	}
+}
+
+func TestMatchTOTPWindow(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code := totpCode(key, current+offset)
+		step, ok := MatchTOTP(rfc6238Secret, code, now)
+		inWindow := offset >= -1 && offset <= 1
+		if ok != inWindow {
+			t.Errorf("code of step %+d: accepted = %v, want %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code of step %+d: matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestMatchTOTPRejectsMalformedInput(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+	now := time.Unix(1234567890, 0)
+	code := totpCode(key, now.Unix()/totpPeriod)
+
+	if _, ok := MatchTOTP("not base32!", code, now); ok {
+		t.Error("accepted a code for an invalid secret")
+	}
+	if _, ok := MatchTOTP(rfc6238Secret, code[:5], now); ok {
+		t.Error("accepted a 5 digit code")
+	}
+	if _, ok := MatchTOTP(rfc6238Secret, "", now); ok {
+		t.Error("accepted an empty code")
+	}
+	// Secrets are accepted the way users may paste them
+	if _, ok := MatchTOTP(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq ", code, now); !ok {
+		t.Error("rejected a lowercase secret with surrounding spaces")
+	}
+}
+
+func TestGenerateTOTPSecretRoundTrip(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q does not decode to 20 bytes: %v", secret, err)
+	}
+
+	now := time.Now()
+	if _, ok := MatchTOTP(secret, totpCode(key, now.Unix()/totpPeriod), now); !ok {
+		t.Error("the current code of a generated secret was rejected")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	format := regexp.MustCompile(`^[a-hjkmnp-z2-9]{5}-[a-hjkmnp-z2-9]{5}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not have the form xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was issued twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRecoveryCodeHashMatchesTypedCode(t *testing.T) {
+	issued := "abcde-fghjk"
+	stored := HashToken(issued)
+
+	// The hash looked up at login must be the stored one however the code is typed
+	for _, typed := range []string{"abcde-fghjk", "ABCDE-FGHJK", "  abcde-fghjk\n"} {
+		if got := HashToken(NormalizeRecoveryCode(typed)); got != stored {
+			t.Errorf("typed %q hashes to %s, want %s", typed, got, stored)
+		}
+	}
+	if HashToken(NormalizeRecoveryCode("abcde-fghjm")) == stored {
+		t.Error("a different code hashes to the stored hash")
+	}
+	if stored == issued {
+		t.Error("recovery codes are stored in plain text")
+	}
+}
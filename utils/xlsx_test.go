@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	cases := map[int]string{
+		0:   "A",
+		1:   "B",
+		25:  "Z",
+		26:  "AA",
+		27:  "AB",
+		51:  "AZ",
+		52:  "BA",
+		701: "ZZ",
+		702: "AAA",
+	}
+	for index, want := range cases {
+		if got := xlsxColumnName(index); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
+
+// xlsxSheet is the part of the sheet XML the tests look at
+type xlsxSheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := NewXLSXWriter(&buf, `Orders & "Returns"`)
+	if err != nil {
+		t.Fatalf("NewXLSXWriter: %v", err)
+	}
+	rows := [][]interface{}{
+		{"id", "name", "amount"},
+		{1, `<b>Rahim & "Sons"</b>`, 1200.5},
+		{2, "bell\x07 and tab\t", 0.0},
+	}
+	for _, row := range rows {
+		if err := x.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := x.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[file.Name] = data
+
+		// Every part must be well-formed XML
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v", file.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatalf("parsing workbook: %v", err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != `Orders & "Returns"` {
+		t.Errorf("sheets = %+v, want one named Orders & \"Returns\"", workbook.Sheets)
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("parsing sheet: %v", err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("sheet has %d rows, want 3", len(sheet.Rows))
+	}
+
+	row := sheet.Rows[1]
+	if row.Ref != "2" || len(row.Cells) != 3 {
+		t.Fatalf("second row = %+v", row)
+	}
+	if c := row.Cells[0]; c.Ref != "A2" || c.Type != "" || c.Value != "1" {
+		t.Errorf("A2 = %+v, want the number 1", c)
+	}
+	if c := row.Cells[1]; c.Ref != "B2" || c.Type != "inlineStr" || c.Inline != `<b>Rahim & "Sons"</b>` {
+		t.Errorf("B2 = %+v, want the escaped text back", c)
+	}
+	if c := row.Cells[2]; c.Ref != "C2" || c.Value != "1200.5" {
+		t.Errorf("C2 = %+v, want the number 1200.5", c)
+	}
+
+	// Characters XML cannot hold are replaced rather than breaking the file
+	if c := sheet.Rows[2].Cells[1]; c.Inline != "bell\ufffd and tab\t" {
+		t.Errorf("B3 = %q, want the control character replaced", c.Inline)
+	}
+	if c := sheet.Rows[2].Cells[2]; c.Value != "0" {
+		t.Errorf("C3 = %+v, want the number 0", c)
+	}
+}